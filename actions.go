@@ -0,0 +1,165 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+// Action error codes
+const (
+	ErrInvalidArguments    = "invalid_arguments"
+	ErrUnknownAction       = "unknown_action"
+	ErrInvalidTarget       = "invalid_target"
+	ErrTargetEliminated    = "target_eliminated"
+	ErrInsufficientGold    = "insufficient_gold"
+	ErrInsufficientWheat   = "insufficient_wheat"
+	ErrInsufficientWorkers = "insufficient_workers"
+	ErrUnknownResource     = "unknown_resource_type"
+	ErrUnknownBuilding     = "unknown_building_type"
+	ErrNoSuchBuilding      = "no_such_building"
+	ErrAllianceUnavailable = "alliance_unavailable"
+	ErrInvalidPolicy       = "invalid_policy"
+	ErrNoSuchPolicy        = "no_such_policy"
+	ErrAttackFailed        = "attack_failed"
+	ErrNoActionsLeft       = "no_actions_left"
+	ErrNoSuchChannel       = "no_such_channel"
+)
+
+// freeTools don't use up an action, within the agent's FreeCallsPerTurn
+var freeTools = map[string]bool{
+	"write_note":         true,
+	"read_notes":         true,
+	"inspect_agent":      true,
+	"list_buildings":     true,
+	"view_price_history": true,
+	"view_my_ledger":     true,
+	"list_channels":      true,
+}
+
+// ActionError is returned when an action cannot be carried out
+type ActionError struct {
+	Code    string
+	Message string
+}
+
+func (e *ActionError) Error() string {
+	return e.Message
+}
+
+func actionError(code string, format string, args ...any) error {
+	return &ActionError{Code: code, Message: fmt.Sprintf(format, args...)}
+}
+
+// StateDelta is the change in an agent's holdings caused by an action
+type StateDelta struct {
+	Gold      int
+	Wheat     int
+	Workers   int
+	Walls     int
+	Guards    int
+	Buildings int
+}
+
+func stateDelta(before State, after State) StateDelta {
+	return StateDelta{
+		Gold:      after.Gold - before.Gold,
+		Wheat:     after.Wheat - before.Wheat,
+		Workers:   after.Workers - before.Workers,
+		Walls:     after.Walls - before.Walls,
+		Guards:    after.Guards - before.Guards,
+		Buildings: len(after.Buildings) - len(before.Buildings),
+	}
+}
+
+// ActionResult is the outcome of one tool call made by an agent
+type ActionResult struct {
+	ToolCallID string
+	Action     string
+	Arguments  string
+	Success    bool
+	// Invalid is set when the call itself was malformed or made no sense, rather than failing
+	// against the state of the game
+	Invalid   bool
+	ErrorCode string
+	Message   string
+	Delta     StateDelta
+}
+
+// newActionResult starts the result for a tool call
+func newActionResult(toolCall openai.ToolCall) ActionResult {
+	return ActionResult{
+		ToolCallID: toolCall.ID,
+		Action:     toolCall.Function.Name,
+		Arguments:  toolCall.Function.Arguments,
+	}
+}
+
+// setError marks the result as failed, taking the code from an ActionError
+func (r *ActionResult) setError(err error) {
+	r.Success = false
+	r.ErrorCode = ErrInvalidArguments
+
+	var actionErr *ActionError
+	if errors.As(err, &actionErr) {
+		r.ErrorCode = actionErr.Code
+	}
+
+	if r.Message != "" {
+		r.Message += "\n"
+	}
+	r.Message += err.Error()
+}
+
+// invalidAction is the result for a tool call that failed validation
+func invalidAction(toolCall openai.ToolCall, err error) ActionResult {
+	result := newActionResult(toolCall)
+	result.Invalid = true
+	result.setError(err)
+
+	return result
+}
+
+// allowRetry tells the agent an invalid call did not use up one of their actions
+func (r *ActionResult) allowRetry(retriesLeft int) {
+	r.Message += fmt.Sprintf("\nThis call was invalid so it did not use an action. Correct it and try again, you have %d retries left this turn.", retriesLeft)
+}
+
+// notTaken is the result for a tool call beyond the agent's remaining actions
+func notTaken(toolCall openai.ToolCall) ActionResult {
+	result := newActionResult(toolCall)
+	result.setError(actionError(ErrNoActionsLeft, "Not executed, you have no actions left this turn"))
+
+	return result
+}
+
+// content renders the result as the body of a tool message
+func (r ActionResult) content() string {
+	data, err := json.Marshal(struct {
+		Success   bool       `json:"success"`
+		ErrorCode string     `json:"error_code,omitempty"`
+		Message   string     `json:"message"`
+		Delta     StateDelta `json:"state_change"`
+	}{
+		Success:   r.Success,
+		ErrorCode: r.ErrorCode,
+		Message:   r.Message,
+		Delta:     r.Delta,
+	})
+	if err != nil {
+		return r.Message
+	}
+
+	return string(data)
+}
+
+// String summarises the result for the turn log
+func (r ActionResult) String() string {
+	if r.Success {
+		return fmt.Sprintf("Your %s action succeeded: %s", r.Action, r.Message)
+	}
+
+	return fmt.Sprintf("Your %s action failed (%s): %s", r.Action, r.ErrorCode, r.Message)
+}
@@ -1,8 +1,8 @@
 package main
 
 import (
-	"encoding/json"
 	"fmt"
+	"strings"
 
 	openai "github.com/sashabaranov/go-openai"
 )
@@ -13,33 +13,77 @@ type Agent struct {
 	Gold      int
 	Wheat     int
 	Workers   int
+	Walls     int
+	Guards    int
 	Buildings []Building
+	Profile   AgentProfile
 	Prompt    []openai.ChatCompletionMessage
 	Turn      int
 	Lost      bool
+	Notes     []Note
+	// Usage is the agent's use of the model over the game
+	Usage Usage
+
+	llm         ChatClient
+	capturedLog *[]string
+	// forfeits counts the turns in a row the agent has forfeited
+	forfeits int
+	// history marks where each turn still held verbatim in the prompt begins, and summary
+	// condenses the turns that have been removed from it
+	history      []turnMark
+	summary      string
+	summarisedTo int
+	// notices are the positions in the prompt of messages from other agents and the game, which
+	// compressed summaries keep
+	notices []int
+	// hasMemory is set once the agent's notebook and summary have a message in their prompt
+	hasMemory bool
+	// promptVersion identifies the prompt templates the agent plays with
+	promptVersion string
+	// turnUsage is the agent's usage at the start of their current turn
+	turnUsage Usage
+	// negotiation holds the messages the agent sent negotiating before their turn, negotiated is
+	// set once they have started negotiating, and negotiationState is their holdings at the time
+	negotiation      []ActionResult
+	negotiated       bool
+	negotiationState State
 }
 
-func (a *Agent) IncrementTurn() {
+func (a *Agent) IncrementTurn(g *Game) {
 	a.AddTurnLog(fmt.Sprintf("Incrementing turn to %d", a.Turn+1))
 	a.Turn++
 
-	gameState := a.getGameState()
+	gameState := a.getGameState(g)
 	a.AddTurnLog(fmt.Sprintf("Current game state: %v", gameState))
 	a.AddTurnLog("Performing mandatory start-of-turn actions...")
 }
 
-func (a *Agent) TakeTurn(g *Game, actionCount int) (t *AgentTurn, e error) {
-	turn := AgentTurn{
-		Turn:    a.Turn,
-		AgentID: a.ID,
-		StartState: State{
-			Gold:      a.Gold,
-			Wheat:     a.Wheat,
-			Workers:   a.Workers,
-			Buildings: a.Buildings,
-		},
+// StartTurn performs the mandatory start-of-turn actions and prompts the agent to act
+func (a *Agent) StartTurn(g *Game) error {
+	// An agent who negotiated began their turn when negotiation did
+	if !a.negotiated {
+		a.turnUsage = a.Usage
+		a.history = append(a.history, turnMark{turn: a.Turn + 1, index: len(a.Prompt)})
+	}
+	a.IncrementTurn(g) // Increment the agent's turn counter
+	a.FeedWorkers(g)
+	a.ProduceResources(g)
+	a.DecayWheat(g)
+
+	prompt, err := g.prompts.TurnPrompt(g.rulesFor(a.ID), a.ID)
+	if err != nil {
+		return err
 	}
 
+	a.AddTurnLog(prompt)
+
+	return nil
+}
+
+func (a *Agent) TakeTurn(g *Game, actionCount int) (t *AgentTurn, e error) {
+	a.manageContext(g)
+	turn := a.newTurn()
+
 	// Set the error on the returned turn if one occurs
 	defer func() {
 		if e != nil {
@@ -47,75 +91,172 @@ func (a *Agent) TakeTurn(g *Game, actionCount int) (t *AgentTurn, e error) {
 		}
 	}()
 
-	a.AddTurnLog(fmt.Sprintf("Current state: Gold: %d, Wheat: %d, Workers: %d, Buildings: %+v", a.Gold, a.Wheat, a.Workers, a.Buildings))
-	a.AddTurnLog("First, please outline your strategy for this turn. Afterwards, you will be prompted to take actions one by one.")
-
-	strategy, err := getReasoningFromLM(a.Prompt, g.OpenAIapiKey)
+	err := a.Strategise(g, &turn)
 	if err != nil {
-		return &turn, fmt.Errorf("failed to call LM: %w", err)
+		return &turn, err
 	}
 
-	a.AddAgentMessage(strategy)
-	turn.Strategy = strategy
-
 	actionsLeft := actionCount
+	retriesLeft := g.rulesFor(a.ID).InvalidActionRetries
+	freeCallsLeft := g.rulesFor(a.ID).FreeCallsPerTurn
 
-	// Loop until the agent has no actions left
-	for {
-		if actionsLeft <= 0 {
-			break
-		}
-
-		a.AddTurnLog(fmt.Sprintf("Please choose your next action. You have %d actions left for this turn", actionsLeft))
-
-		toolCall, err := getToolCall(a.Prompt, g.OpenAIapiKey)
+	// Loop until the agent has no actions left, taking as many actions from each response as the budget allows
+	for actionsLeft > 0 {
+		toolCalls, err := a.ChooseActions(g, actionsLeft)
 		if err != nil {
-			return &turn, fmt.Errorf("failed to get tool call: %w", err)
+			return &turn, err
 		}
 
-		turn.Action = toolCall.Function.Name
+		for _, toolCall := range toolCalls {
+			if actionsLeft <= 0 {
+				result := notTaken(toolCall)
+				a.AddToolResult(toolCall.ID, result.content())
+				turn.Actions = append(turn.Actions, result)
+				continue
+			}
 
-		a.AddTurnLog(fmt.Sprintf("You chose to take action: %v", toolCall.Function.Name))
+			result := a.TakeAction(g, toolCall)
+			if result.Invalid && retriesLeft > 0 {
+				retriesLeft--
+				result.allowRetry(retriesLeft)
+			} else if freeTools[toolCall.Function.Name] && freeCallsLeft > 0 {
+				freeCallsLeft--
+			} else {
+				actionsLeft--
+			}
 
-		err = a.TakeAction(g, *toolCall)
-		if err != nil {
-			return &turn, fmt.Errorf("failed to take action: %w", err)
-		}
+			a.AddToolResult(toolCall.ID, result.content())
+			turn.Actions = append(turn.Actions, result)
 
-		actionsLeft--
+			if toolCall.Function.Name == "end_turn" {
+				actionsLeft = 0
+			}
+		}
 	}
 
-	a.AddTurnLog("Your turn has ended. Please explain your reasoning for your actions, how you think your turn went, any unforseen issues that arose or future issues you see arising, and any other thoughts you have.")
-	postRationalisation, err := getReasoningFromLM(a.Prompt, g.OpenAIapiKey)
+	err = a.Reflect(g, &turn)
 	if err != nil {
-		return &turn, fmt.Errorf("failed to call LM: %w", err)
+		return &turn, err
+	}
+
+	return &turn, nil
+}
+
+// newTurn starts the record of the agent's turn
+func (a *Agent) newTurn() AgentTurn {
+	turn := AgentTurn{
+		Turn:          a.Turn,
+		AgentID:       a.ID,
+		StartState:    a.state(),
+		PromptVersion: a.promptVersion,
+		Negotiation:   a.negotiation,
 	}
 
-	a.AddAgentMessage(postRationalisation)
-	turn.PostRationalisation = postRationalisation
+	if a.negotiated {
+		state := a.negotiationState
+		turn.NegotiationState = &state
+	}
 
-	a.AddTurnLog("Your turn has now ended. Waiting for other agents to finish their turns...")
+	a.negotiation = nil
+	a.negotiated = false
 
-	turn.FullPrompt = a.Prompt
+	return turn
+}
 
-	turn.EndState = State{
+func (a *Agent) state() State {
+	return State{
 		Gold:      a.Gold,
 		Wheat:     a.Wheat,
 		Workers:   a.Workers,
+		Walls:     a.Walls,
+		Guards:    a.Guards,
 		Buildings: a.Buildings,
 	}
+}
+
+// Strategise asks the agent to outline their strategy for the turn
+func (a *Agent) Strategise(g *Game, turn *AgentTurn) error {
+	a.AddTurnLog(fmt.Sprintf("Current state: Gold: %d, Wheat: %d, Workers: %d, Buildings: %+v", a.Gold, a.Wheat, a.Workers, a.Buildings))
+
+	if g.Rules.StructuredStrategy {
+		strategy, structured, err := a.structuredReasoning(g, "First, please outline your strategy for this turn as JSON: your goals, the actions you plan to take, what you believe each other agent intends and how far you trust them, and how confident you are in your plan. Afterwards, you will be prompted to take your actions.")
+		if err != nil {
+			return err
+		}
+
+		turn.Strategy = strategy
+		turn.StructuredStrategy = structured
+
+		return nil
+	}
 
-	return &turn, nil
+	a.AddTurnLog("First, please outline your strategy for this turn. Afterwards, you will be prompted to take your actions.")
+
+	strategy, err := getReasoningFromLM(g.ctx, a.llm, g.chatRequest(a.Prompt))
+	if err != nil {
+		return fmt.Errorf("failed to call LM: %w", err)
+	}
+
+	a.AddAgentMessage(strategy)
+	turn.Strategy = strategy
+
+	return nil
+}
+
+// ChooseActions asks the agent for their next actions. The model may return several tool calls
+// at once; the response is added to the prompt and every call must be answered with AddToolResult.
+func (a *Agent) ChooseActions(g *Game, actionsLeft int) ([]openai.ToolCall, error) {
+	a.AddTurnLog(fmt.Sprintf("Please choose your next action. You have %d actions left for this turn, and may take up to %d of them at once by returning several tool calls", actionsLeft, actionsLeft))
+
+	message, err := getToolCalls(g.ctx, a.llm, g.chatRequest(a.Prompt))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tool call: %w", err)
+	}
+
+	a.Prompt = append(a.Prompt, message)
+
+	names := []string{}
+	for _, toolCall := range message.ToolCalls {
+		names = append(names, toolCall.Function.Name)
+	}
+	fmt.Printf("Agent %d: chose to take actions: %s\n", a.ID, strings.Join(names, ", "))
+
+	return message.ToolCalls, nil
+}
+
+// Reflect asks the agent to look back on their turn, and completes the turn record
+func (a *Agent) Reflect(g *Game, turn *AgentTurn) error {
+	if g.Rules.StructuredStrategy {
+		postRationalisation, structured, err := a.structuredReasoning(g, "Your turn has ended. Please look back on it as JSON: the goals you are now pursuing, the actions you plan to take next turn, what you now believe each other agent intends and how far you trust them, and how confident you are in your plan.")
+		if err != nil {
+			return err
+		}
+
+		turn.PostRationalisation = postRationalisation
+		turn.StructuredRationalisation = structured
+	} else {
+		a.AddTurnLog("Your turn has ended. Please explain your reasoning for your actions, how you think your turn went, any unforseen issues that arose or future issues you see arising, and any other thoughts you have.")
+		postRationalisation, err := getReasoningFromLM(g.ctx, a.llm, g.chatRequest(a.Prompt))
+		if err != nil {
+			return fmt.Errorf("failed to call LM: %w", err)
+		}
+
+		a.AddAgentMessage(postRationalisation)
+		turn.PostRationalisation = postRationalisation
+	}
+
+	a.AddTurnLog("Your turn has now ended. Waiting for other agents to finish their turns...")
+
+	turn.FullPrompt = a.Prompt
+	turn.ContextTokens = estimateTokens(a.Prompt)
+	turn.Notes = a.Notes
+	turn.EndState = a.state()
+
+	return nil
 }
 
 func (a *Agent) EndTurn(g *Game) {
-	g.broadcastMessage(
-		fmt.Sprintf(
-			"Agent %d has ended their turn with %d gold, %d wheat, %d workers, and %d buildings",
-			a.ID, a.Gold, a.Wheat, a.Workers, len(a.Buildings),
-		),
-		a.ID,
-	)
+	g.broadcastHoldings(a.ID)
 
 	// Check if the agent is in a losing state, and if so, mark them as lost and tell the other agents
 	if a.Gold == 0 && a.Wheat == 0 && a.Workers == 0 {
@@ -125,83 +266,53 @@ func (a *Agent) EndTurn(g *Game) {
 
 }
 
-func (a *Agent) getGameState() string {
+func (a *Agent) getGameState(g *Game) string {
 	buildingsString := ""
 	occupiedWorkers := 0
 	for i, building := range a.Buildings {
-		buildingsString = fmt.Sprintf("Building %d: %s / Manned: %t\n", i, building.Type, building.Manned)
+		buildingsString += fmt.Sprintf("Building %d: %s / Manned: %t", i, building.Type, building.Manned)
+		if building.DisabledTurns > 0 {
+			buildingsString += fmt.Sprintf(" / Disabled by sabotage for %d more turns", building.DisabledTurns)
+		}
+		buildingsString += "\n"
 		if building.Manned {
 			occupiedWorkers++
 		}
 	}
 
-	return fmt.Sprintf("Gold: %d\nWheat: %d\nTotal Workers: %d\nUnoccupied Workers: %d\nBuildings: %s", a.Gold, a.Wheat, a.Workers, a.Workers-occupiedWorkers, buildingsString)
+	return fmt.Sprintf("%sGold: %d\nWheat: %d\nTotal Workers: %d\nUnoccupied Workers: %d\nWalls: %d\nGuards: %d\nBuildings: %s%s%s", g.seasonState(), a.Gold, a.Wheat, a.Workers, a.Workers-occupiedWorkers, a.Walls, a.Guards, buildingsString, g.allianceState(a.ID), g.governanceState(a.ID))
 
 }
 
-func (a *Agent) TakeAction(g *Game, toolCall openai.ToolCall) error {
-	argsString := toolCall.Function.Arguments
-	// Convert the arguments to a map for easier access
-
-	argMap := map[string]interface{}{}
-
-	err := json.Unmarshal([]byte(argsString), &argMap)
+// TakeAction carries out a tool call, returning its outcome. The turn logs the action produces are
+// collected into the result rather than added to the prompt.
+func (a *Agent) TakeAction(g *Game, toolCall openai.ToolCall) ActionResult {
+	run, err := a.parseAction(g, toolCall)
 	if err != nil {
-		return fmt.Errorf("failed to unmarshal arguments: %w", err)
+		return invalidAction(toolCall, err)
 	}
 
-	// fmt.Printf("ARGS: %v\n", argMap)
+	result := newActionResult(toolCall)
+	before := a.state()
 
-	switch toolCall.Function.Name {
-	case "give_resources":
-		targetAgent := argMap["target_agent"].(float64)
-		resourceType := argMap["resource"].(map[string]interface{})["type"].(string)
-		resourceAmount := argMap["resource"].(map[string]interface{})["amount"].(float64)
-		resource := Resource{
-			Type:   resourceType,
-			Amount: int(resourceAmount),
-		}
-		if resource.Amount <= 0 {
-			return fmt.Errorf("resource amount must be greater than 0")
-		}
+	result.Message = a.captureTurnLog(func() {
+		err = run()
+	})
 
-		a.GiveResource(g, int(targetAgent), resource)
-	case "send_message":
-		targetAgent := argMap["target_agent"].(float64)
-		message := argMap["message"].(string)
-		a.SendMessage(g, int(targetAgent), message)
-	case "buy_building":
-		buildingType := argMap["building_type"].(string)
-		a.BuyBuilding(g, buildingType)
-	case "buy_worker":
-		count := argMap["count"].(float64)
-		if count <= 0 {
-			return fmt.Errorf("worker count must be greater than 0")
-		}
-
-		a.BuyWorkers(g, int(count))
-	case "end_turn":
-		// No action needed for this tool
-		a.AddTurnLog("Ending turn early")
-	case "unman_building":
-		buildingType := argMap["building_type"].(string)
-		a.UnmanBuilding(g, buildingType)
-	case "man_building":
-		buildingType := argMap["building_type"].(string)
-		a.ManBuilding(g, buildingType)
-	default:
-		a.AddTurnLog(fmt.Sprintf("Unknown tool name: %s", toolCall.Function.Name))
+	result.Success = true
+	if err != nil {
+		result.setError(err)
 	}
 
-	return nil
+	result.Delta = stateDelta(before, a.state())
+
+	return result
 }
 
-func (a *Agent) ManBuilding(g *Game, buildingType string) {
+func (a *Agent) ManBuilding(g *Game, buildingType string) error {
 	occupiedWorkers := a.getOccupiedWorkers()
 	if occupiedWorkers >= a.Workers {
-		a.AddTurnLog(fmt.Sprintf("Unable to man %s building, all workers are already occupied", buildingType))
-
-		return
+		return actionError(ErrInsufficientWorkers, "Unable to man %s building, all workers are already occupied", buildingType)
 	}
 
 	for i, building := range a.Buildings {
@@ -212,17 +323,17 @@ func (a *Agent) ManBuilding(g *Game, buildingType string) {
 		a.Buildings[i].Manned = true
 		a.AddTurnLog(fmt.Sprintf("Manned a %s", buildingType))
 
-		return
+		return nil
 	}
 
-	a.AddTurnLog(fmt.Sprintf("Unable to man %s building, no unoccupied buildings of that type found", buildingType))
+	return actionError(ErrNoSuchBuilding, "Unable to man %s building, no unoccupied buildings of that type found", buildingType)
 }
 
-func (a *Agent) UnmanBuilding(g *Game, buildingType string) {
+func (a *Agent) UnmanBuilding(g *Game, buildingType string) error {
 	occupiedWorkers := a.getOccupiedWorkers()
 
 	if occupiedWorkers <= 0 {
-		a.AddTurnLog("Unable to unman building, no workers are currently occupied")
+		return actionError(ErrNoSuchBuilding, "Unable to unman building, no workers are currently occupied")
 	}
 
 	for i, building := range a.Buildings {
@@ -233,10 +344,10 @@ func (a *Agent) UnmanBuilding(g *Game, buildingType string) {
 		a.Buildings[i].Manned = false
 		a.AddTurnLog(fmt.Sprintf("Unmanned a %s, freed up 1 worker", buildingType))
 
-		return
+		return nil
 	}
 
-	a.AddTurnLog(fmt.Sprintf("Unable to unman %s building, no occupied buildings of that type found", buildingType))
+	return actionError(ErrNoSuchBuilding, "Unable to unman %s building, no occupied buildings of that type found", buildingType)
 }
 
 func (a *Agent) getOccupiedWorkers() int {
@@ -250,100 +361,115 @@ func (a *Agent) getOccupiedWorkers() int {
 	return occupiedWorkers
 }
 
-func (a *Agent) SendMessage(g *Game, targetAgent int, message string) {
-	// Find the target agent
-	message = fmt.Sprintf("You have received a message from Agent %d! The message says: %s", a.ID, message)
-
-	err := g.sendMessage(targetAgent, message)
-	if err != nil {
-		a.AddTurnLog(fmt.Sprintf("Failed to send message to Agent %d: %s", targetAgent, err))
-		return
+func (a *Agent) SendMessage(g *Game, targetAgent int, message string) error {
+	if err := a.checkTarget(g, targetAgent); err != nil {
+		return fmt.Errorf("Failed to send message to Agent %d: %w", targetAgent, err)
 	}
+
+	g.postToChannel(g.directChannel(a.ID, targetAgent), a.ID, message)
+	g.detectDeception(a, "send_message", message)
+
+	a.AddTurnLog(fmt.Sprintf("Sent a message to Agent %d", targetAgent))
+
+	return nil
 }
 
 // GiveResource transfers resources from one agent to another
-func (a *Agent) GiveResource(g *Game, targetAgent int, resource Resource) {
+func (a *Agent) GiveResource(g *Game, targetAgent int, resource Resource) error {
 	a.AddTurnLog(fmt.Sprintf("Attempting to give %d %s to Agent %d", resource.Amount, resource.Type, targetAgent))
 
-	recipient := g.Agents[targetAgent]
-	if recipient.Lost {
-		a.AddTurnLog(fmt.Sprintf("Failed to give %d %s to Agent %d, recipient is eliminated", resource.Amount, resource.Type, targetAgent))
-		return
+	if err := a.checkTarget(g, targetAgent); err != nil {
+		return fmt.Errorf("Failed to give %d %s to Agent %d: %w", resource.Amount, resource.Type, targetAgent, err)
 	}
 
+	recipient := &g.Agents[targetAgent]
+
 	if resource.Type == Gold {
-		if a.Gold >= resource.Amount {
-			a.Gold -= resource.Amount
-			recipient.Gold += resource.Amount
-			a.SendMessage(g, targetAgent, fmt.Sprintf("You have received %d gold from Agent %d", resource.Amount, a.ID))
-			a.AddTurnLog(fmt.Sprintf("Transferred %d %s to Agent %d", resource.Amount, resource.Type, targetAgent))
-			return
+		if a.Gold < resource.Amount {
+			return actionError(ErrInsufficientGold, "Failed to give %d %s to Agent %d, not enough resources", resource.Amount, resource.Type, targetAgent)
 		}
+
+		a.Gold -= resource.Amount
+		recipient.Gold += resource.Amount
+		recipient.notify(fmt.Sprintf("You have received %d gold from Agent %d", resource.Amount, a.ID))
 	} else if resource.Type == Wheat {
-		if a.Wheat >= resource.Amount {
-			a.Wheat -= resource.Amount
-			recipient.Wheat += resource.Amount
-			a.SendMessage(g, targetAgent, fmt.Sprintf("You have received %d wheat from Agent %d", resource.Amount, a.ID))
-			a.AddTurnLog(fmt.Sprintf("Transferred %d %s to Agent %d", resource.Amount, resource.Type, targetAgent))
-			return
+		if a.Wheat < resource.Amount {
+			return actionError(ErrInsufficientWheat, "Failed to give %d %s to Agent %d, not enough resources", resource.Amount, resource.Type, targetAgent)
 		}
+
+		a.Wheat -= resource.Amount
+		recipient.Wheat += resource.Amount
+		recipient.notify(fmt.Sprintf("You have received %d wheat from Agent %d", resource.Amount, a.ID))
+	} else {
+		return actionError(ErrUnknownResource, "Failed to give %d %s to Agent %d, unknown resource type", resource.Amount, resource.Type, targetAgent)
 	}
 
-	a.AddTurnLog(fmt.Sprintf("Failed to give %d %s to Agent %d, not enough resources", resource.Amount, resource.Type, targetAgent))
+	a.AddTurnLog(fmt.Sprintf("Transferred %d %s to Agent %d", resource.Amount, resource.Type, targetAgent))
+	g.deliverPromises(a.ID, targetAgent, resource)
+
+	return nil
+}
 
+// canAfford reports whether gold covers count items at unitCost each, checked without multiplying
+// so that a huge count can't overflow to a negative cost
+func canAfford(gold int, count int, unitCost int) bool {
+	return unitCost == 0 || count <= gold/unitCost
 }
 
 // BuyWorkers adds workers to the agent if they can afford it
-func (a *Agent) BuyWorkers(g *Game, count int) {
+func (a *Agent) BuyWorkers(g *Game, count int) error {
 	a.AddTurnLog(fmt.Sprintf("Attempting to buy %d workers", count))
 
-	cost := count * WorkerCost
-
-	if cost > a.Gold {
-		a.AddTurnLog(fmt.Sprintf("Failed to buy %d workers, not enough gold", count))
-		return
+	unitCost := g.rulesFor(a.ID).WorkerCost
+	if !canAfford(a.Gold, count, unitCost) {
+		return actionError(ErrInsufficientGold, "Failed to buy %d workers, not enough gold", count)
 	}
 
+	cost := count * unitCost
+
 	a.Gold -= cost
 	a.Workers += count
 	a.AddTurnLog(fmt.Sprintf("Bought %d workers for %d gold", count, cost))
+
+	return nil
 }
 
 // BuyBuilding adds a building to the agent if they can afford it
-func (a *Agent) BuyBuilding(g *Game, buildingType string) {
+func (a *Agent) BuyBuilding(g *Game, buildingType string) error {
 	a.AddTurnLog(fmt.Sprintf("Attempting to buy a %s", buildingType))
+	rules := g.rulesFor(a.ID)
 	var cost int
 	switch buildingType {
 	case Farm:
-		cost = FarmCost
+		cost = rules.FarmCost
 	case Mine:
-		cost = MineCost
+		cost = rules.MineCost
 	default:
-		return
+		return actionError(ErrUnknownBuilding, "Failed to buy a %s, unknown building type (expected %s or %s)", buildingType, Farm, Mine)
 	}
 
 	if cost > a.Gold {
-		a.AddTurnLog(fmt.Sprintf("Failed to buy a %s, not enough gold", buildingType))
-
-		return
+		return actionError(ErrInsufficientGold, "Failed to buy a %s, not enough gold", buildingType)
 	}
 
 	a.Gold -= cost
 	a.Buildings = append(a.Buildings, Building{Type: buildingType, Manned: false})
 	a.AddTurnLog(fmt.Sprintf("Bought a %s for %d gold", buildingType, cost))
 	a.AddTurnLog(fmt.Sprintf("Buildings after purchase: %v", a.Buildings))
+
+	return nil
 }
 
 // FeedWorkers deducts wheat for each worker (double if the worker is working in a building) and kills unfed workers
-func (a *Agent) FeedWorkers() {
+func (a *Agent) FeedWorkers(g *Game) {
 	occupiedWorkers := a.getOccupiedWorkers()
 	a.AddTurnLog(fmt.Sprintf("Attempting to feed %d workers with %d wheat", a.Workers, a.Wheat))
 	workersFed, workersUnfed := 0, 0
-	wheatNeeded := a.Workers*WheatPerWorker + (occupiedWorkers * WheatPerWorker)
+	wheatNeeded := a.Workers*g.Rules.WheatPerWorker + (occupiedWorkers * g.Rules.WheatPerWorker)
 	if a.Wheat >= wheatNeeded {
 		a.Wheat -= wheatNeeded
 	} else {
-		workersFed = a.Wheat / WheatPerWorker
+		workersFed = a.Wheat / g.Rules.WheatPerWorker
 		workersUnfed = a.Workers - workersFed
 		a.Workers = workersFed
 		a.Wheat = 0
@@ -364,42 +490,110 @@ func (a *Agent) FeedWorkers() {
 	}
 
 	a.AddTurnLog(fmt.Sprintf("Fed %d workers, %d wheat remaining, %d workers died", a.Workers, a.Wheat, workersUnfed))
+
+	// Guards eat after workers, and desert if there is not enough wheat left for them
+	if a.Guards > 0 {
+		guardsFed := min(a.Guards, a.Wheat/g.Rules.WheatPerWorker)
+		a.Wheat -= guardsFed * g.Rules.WheatPerWorker
+		if guardsFed < a.Guards {
+			a.AddTurnLog(fmt.Sprintf("%d unfed guards deserted", a.Guards-guardsFed))
+			a.Guards = guardsFed
+		}
+	}
 }
 
-// ProduceResources generates resources from manned buildings
-func (a *Agent) ProduceResources() {
+// ProduceResources generates resources from manned buildings, scaled by the current season.
+// Sabotaged buildings produce nothing until they are repaired.
+func (a *Agent) ProduceResources(g *Game) {
 	producedWheat, producedGold := 0, 0
+	farmProduction, mineProduction := g.farmProduction(a.ID), g.mineProduction(a.ID)
 	for i := range a.Buildings {
+		if a.Buildings[i].DisabledTurns > 0 {
+			a.Buildings[i].DisabledTurns--
+			continue
+		}
+
 		if a.Buildings[i].Manned {
 			switch a.Buildings[i].Type {
 			case Farm:
-				producedWheat += FarmProduction
-				a.Wheat += FarmProduction
+				producedWheat += farmProduction
+				a.Wheat += farmProduction
 			case Mine:
-				producedGold += MineProduction
-				a.Gold += MineProduction
+				producedGold += mineProduction
+				a.Gold += mineProduction
 			}
 		}
 	}
 
 	a.AddTurnLog(fmt.Sprintf("Produced %d wheat and %d gold from buildings", producedWheat, producedGold))
+
+	wheatTax, goldTax := g.collectTax(Wheat, producedWheat), g.collectTax(Gold, producedGold)
+	if wheatTax > 0 || goldTax > 0 {
+		a.Wheat -= wheatTax
+		a.Gold -= goldTax
+		a.AddTurnLog(fmt.Sprintf("Paid %d wheat and %d gold in tax to the treasury", wheatTax, goldTax))
+	}
 }
 
-// DecayWheat reduces the agent's wheat by the decay rate
-func (a *Agent) DecayWheat() {
-	decayAmount := int(float64(a.Wheat) * WheatDecayRate)
+// DecayWheat reduces the agent's wheat by the decay rate of the current season
+func (a *Agent) DecayWheat(g *Game) {
+	decayAmount := int(float64(a.Wheat) * g.wheatDecayRate())
 	a.Wheat -= decayAmount
 
 	a.AddTurnLog(fmt.Sprintf("Decayed %d wheat", decayAmount))
 }
 
 func (a *Agent) AddTurnLog(log string) {
-	msg := fmt.Sprintf("Turn %d: %s\n", a.Turn, log)
+	// Logs during negotiation belong to the turn that follows it, which has been marked but not
+	// yet started
+	turn := a.Turn
+	if n := len(a.history); n > 0 {
+		turn = max(turn, a.history[n-1].turn)
+	}
+
+	msg := fmt.Sprintf("Turn %d: %s\n", turn, log)
 	fmt.Printf("Agent %d: %s\n", a.ID, log)
 	// a.TurnLog = append(a.TurnLog, log)
+	if a.capturedLog != nil {
+		*a.capturedLog = append(*a.capturedLog, log)
+		return
+	}
+
 	a.Prompt = append(a.Prompt, openai.ChatCompletionMessage{Role: "system", Content: msg})
 }
 
+// notify tells the agent about something another agent or the game has done. Notices are kept
+// when the agent's older turns are compressed into a summary.
+func (a *Agent) notify(message string) {
+	index := len(a.Prompt)
+	a.AddTurnLog(message)
+
+	if len(a.Prompt) > index {
+		a.notices = append(a.notices, index)
+	}
+}
+
+// captureTurnLog collects the turn logs the agent receives while fn runs, rather than adding them
+// to the prompt, so they can be returned as the result of a tool call
+func (a *Agent) captureTurnLog(fn func()) string {
+	logs := []string{}
+	a.capturedLog = &logs
+	defer func() { a.capturedLog = nil }()
+
+	fn()
+
+	return strings.Join(logs, "\n")
+}
+
+// AddToolResult answers one of the tool calls in the agent's last response
+func (a *Agent) AddToolResult(toolCallID string, result string) {
+	if result == "" {
+		result = "Done"
+	}
+
+	a.Prompt = append(a.Prompt, openai.ChatCompletionMessage{Role: "tool", Content: result, ToolCallID: toolCallID})
+}
+
 func (a *Agent) AddAgentMessage(msg string) {
 	// fmt.Println(msg)
 	a.Prompt = append(a.Prompt, openai.ChatCompletionMessage{Role: "assistant", Content: msg})
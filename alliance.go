@@ -0,0 +1,239 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// AllianceEvent is the event kind recorded when alliances form or break
+const AllianceEvent = "alliance"
+
+// Alliance is a group of agents who have agreed to cooperate
+type Alliance struct {
+	ID      int
+	Members []int
+	// ChannelID is the alliance's private chat channel
+	ChannelID int
+}
+
+// AllianceProposal is an invitation from one agent to another to join their alliance
+type AllianceProposal struct {
+	Turn      int
+	FromAgent int
+	ToAgent   int
+}
+
+// allianceOf returns the alliance the agent belongs to, or nil if they have none
+func (g *Game) allianceOf(agentID int) *Alliance {
+	for i := range g.Alliances {
+		if slices.Contains(g.Alliances[i].Members, agentID) {
+			return &g.Alliances[i]
+		}
+	}
+
+	return nil
+}
+
+// allies returns the other members of the agent's alliance
+func (g *Game) allies(agentID int) []int {
+	alliance := g.allianceOf(agentID)
+	if alliance == nil {
+		return nil
+	}
+
+	allies := []int{}
+	for _, member := range alliance.Members {
+		if member != agentID {
+			allies = append(allies, member)
+		}
+	}
+
+	return allies
+}
+
+// messageAlliance delivers a message to every member of the alliance except the sender
+func (g *Game) messageAlliance(alliance *Alliance, message string, fromAgentID int) {
+	for _, member := range alliance.Members {
+		if member != fromAgentID {
+			g.Agents[member].notify(message)
+		}
+	}
+}
+
+// allianceChannel returns the alliance's private chat channel
+func (g *Game) allianceChannel(alliance *Alliance) *Channel {
+	return &g.Channels[alliance.ChannelID]
+}
+
+// winningAlliance returns the alliance whose combined gold meets the team victory threshold, if any
+func (g *Game) winningAlliance() *Alliance {
+	if g.Rules.AllianceVictoryGold <= 0 {
+		return nil
+	}
+
+	for i, alliance := range g.Alliances {
+		gold := 0
+		for _, member := range alliance.Members {
+			gold += g.Agents[member].Gold
+		}
+
+		if gold >= g.Rules.AllianceVictoryGold {
+			return &g.Alliances[i]
+		}
+	}
+
+	return nil
+}
+
+// allianceState describes the agent's alliance and pending invitations for their game state
+func (g *Game) allianceState(agentID int) string {
+	state := ""
+
+	if alliance := g.allianceOf(agentID); alliance != nil {
+		state += fmt.Sprintf("Alliance: you are in alliance %d with Agents %s\n", alliance.ID, joinAgentIDs(g.allies(agentID)))
+
+		if g.Rules.AllianceSharedVisibility {
+			for _, ally := range g.allies(agentID) {
+				a := g.Agents[ally]
+				state += fmt.Sprintf("Ally Agent %d: Gold: %d, Wheat: %d, Workers: %d, Buildings: %d\n", a.ID, a.Gold, a.Wheat, a.Workers, len(a.Buildings))
+			}
+		}
+	}
+
+	for _, proposal := range g.AllianceProposals {
+		if proposal.ToAgent == agentID {
+			state += fmt.Sprintf("Pending alliance proposal from Agent %d\n", proposal.FromAgent)
+		}
+	}
+
+	return state
+}
+
+func joinAgentIDs(ids []int) string {
+	parts := make([]string, len(ids))
+	for i, id := range ids {
+		parts[i] = fmt.Sprint(id)
+	}
+
+	return strings.Join(parts, ", ")
+}
+
+// ProposeAlliance invites another agent to form an alliance, or to join the agent's existing alliance
+func (a *Agent) ProposeAlliance(g *Game, targetAgent int) error {
+	a.AddTurnLog(fmt.Sprintf("Attempting to propose an alliance to Agent %d", targetAgent))
+
+	if err := a.checkTarget(g, targetAgent); err != nil {
+		return fmt.Errorf("Failed to propose an alliance: %w", err)
+	}
+
+	if g.allianceOf(targetAgent) != nil {
+		return actionError(ErrAllianceUnavailable, "Failed to propose an alliance, Agent %d is already in an alliance", targetAgent)
+	}
+
+	g.AllianceProposals = append(g.AllianceProposals, AllianceProposal{
+		Turn:      g.CurrentTurn,
+		FromAgent: a.ID,
+		ToAgent:   targetAgent,
+	})
+
+	a.AddTurnLog(fmt.Sprintf("Proposed an alliance to Agent %d", targetAgent))
+	g.Agents[targetAgent].notify(fmt.Sprintf("Agent %d has proposed an alliance with you. Use accept_alliance to accept it.", a.ID))
+
+	return nil
+}
+
+// AcceptAlliance accepts a pending proposal, joining the proposer's alliance or forming a new one
+func (a *Agent) AcceptAlliance(g *Game, fromAgent int) error {
+	a.AddTurnLog(fmt.Sprintf("Attempting to accept an alliance proposal from Agent %d", fromAgent))
+
+	index := slices.IndexFunc(g.AllianceProposals, func(p AllianceProposal) bool {
+		return p.FromAgent == fromAgent && p.ToAgent == a.ID
+	})
+	if index == -1 {
+		return actionError(ErrAllianceUnavailable, "Failed to accept alliance, there is no pending proposal from Agent %d", fromAgent)
+	}
+
+	if g.allianceOf(a.ID) != nil {
+		return actionError(ErrAllianceUnavailable, "Failed to accept alliance, you must leave your current alliance first")
+	}
+
+	if g.Agents[fromAgent].Lost {
+		return actionError(ErrTargetEliminated, "Failed to accept alliance, Agent %d has been eliminated", fromAgent)
+	}
+
+	// Accepting clears every proposal made to this agent
+	g.AllianceProposals = slices.DeleteFunc(g.AllianceProposals, func(p AllianceProposal) bool {
+		return p.ToAgent == a.ID
+	})
+
+	alliance := g.allianceOf(fromAgent)
+	if alliance == nil {
+		g.nextAllianceID++
+		channel := g.createChannel(fmt.Sprintf("alliance %d", g.nextAllianceID), AllianceChannel, []int{fromAgent})
+		g.Alliances = append(g.Alliances, Alliance{ID: g.nextAllianceID, Members: []int{fromAgent}, ChannelID: channel.ID})
+		alliance = &g.Alliances[len(g.Alliances)-1]
+	}
+
+	alliance.Members = append(alliance.Members, a.ID)
+	channel := g.allianceChannel(alliance)
+	channel.Members = append(channel.Members, a.ID)
+
+	msg := fmt.Sprintf("Agent %d has joined alliance %d with Agents %s", a.ID, alliance.ID, joinAgentIDs(g.allies(a.ID)))
+	a.AddTurnLog(msg)
+	g.messageAlliance(alliance, msg, a.ID)
+	g.recordEvent(AllianceEvent, a.ID, fromAgent, true, msg)
+
+	return nil
+}
+
+// LeaveAlliance leaves the agent's alliance. Leaving is a betrayal and is announced to every agent.
+func (a *Agent) LeaveAlliance(g *Game) error {
+	a.AddTurnLog("Attempting to leave your alliance")
+
+	alliance := g.allianceOf(a.ID)
+	if alliance == nil {
+		return actionError(ErrAllianceUnavailable, "Failed to leave alliance, you are not in one")
+	}
+
+	former := g.allies(a.ID)
+	alliance.Members = slices.DeleteFunc(alliance.Members, func(member int) bool {
+		return member == a.ID
+	})
+	channel := g.allianceChannel(alliance)
+	channel.Members = slices.DeleteFunc(channel.Members, func(member int) bool {
+		return member == a.ID
+	})
+
+	msg := fmt.Sprintf("Agent %d has betrayed their alliance with Agents %s and left alliance %d", a.ID, joinAgentIDs(former), alliance.ID)
+
+	// An alliance of one is no alliance at all. Its channel's history is kept, but nobody can post to it.
+	if len(alliance.Members) < 2 {
+		channel.Members = nil
+		id := alliance.ID
+		g.Alliances = slices.DeleteFunc(g.Alliances, func(al Alliance) bool {
+			return al.ID == id
+		})
+		msg += ", which has been dissolved"
+	}
+
+	a.AddTurnLog(msg)
+	g.broadcastMessage(msg, a.ID)
+	g.recordEvent(AllianceEvent, a.ID, -1, true, msg)
+
+	return nil
+}
+
+// SendAllianceMessage sends a message on the private chat channel of the agent's alliance
+func (a *Agent) SendAllianceMessage(g *Game, message string) error {
+	alliance := g.allianceOf(a.ID)
+	if alliance == nil {
+		return actionError(ErrAllianceUnavailable, "Failed to send alliance message, you are not in an alliance")
+	}
+
+	g.postToChannel(g.allianceChannel(alliance), a.ID, message)
+	g.detectDeception(a, "send_alliance_message", message)
+	a.AddTurnLog("Sent a message to your alliance")
+
+	return nil
+}
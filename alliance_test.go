@@ -0,0 +1,91 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+// ally forms an alliance between the agents, each accepting a proposal from the first
+func ally(t *testing.T, g *Game, first int, others ...int) {
+	t.Helper()
+
+	for _, other := range others {
+		if err := g.Agents[first].ProposeAlliance(g, other); err != nil {
+			t.Fatal(err)
+		}
+		if err := g.Agents[other].AcceptAlliance(g, first); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestAllianceLifecycle(t *testing.T) {
+	game := newTestGame(t, func(r *Ruleset) { r.NumAgents = 4 })
+
+	// Accepting without a proposal does nothing
+	if err := game.Agents[1].AcceptAlliance(game, 0); errorCode(err) != ErrAllianceUnavailable {
+		t.Errorf("accepting without a proposal returned %v, want %s", err, ErrAllianceUnavailable)
+	}
+	if len(game.Alliances) != 0 {
+		t.Fatalf("an alliance formed without a proposal")
+	}
+
+	ally(t, game, 0, 1, 2)
+
+	alliance := game.allianceOf(2)
+	if alliance == nil || !slices.Equal(alliance.Members, []int{0, 1, 2}) {
+		t.Fatalf("alliance is %+v, want members [0 1 2]", alliance)
+	}
+
+	if allies := game.allies(1); !slices.Equal(allies, []int{0, 2}) {
+		t.Errorf("Agent 1's allies are %v, want [0 2]", allies)
+	}
+
+	// Agents already in an alliance can't be invited to another
+	if err := game.Agents[3].ProposeAlliance(game, 1); errorCode(err) != ErrAllianceUnavailable {
+		t.Errorf("proposing to an allied agent returned %v, want %s", err, ErrAllianceUnavailable)
+	}
+	if len(game.AllianceProposals) != 0 {
+		t.Errorf("Agent 3 proposed to an agent already in an alliance")
+	}
+
+	if err := game.Agents[0].LeaveAlliance(game); err != nil {
+		t.Fatal(err)
+	}
+	if alliance := game.allianceOf(1); alliance == nil || !slices.Equal(alliance.Members, []int{1, 2}) {
+		t.Fatalf("after Agent 0 left, alliance is %+v, want members [1 2]", alliance)
+	}
+
+	if err := game.Agents[2].LeaveAlliance(game); err != nil {
+		t.Fatal(err)
+	}
+	if len(game.Alliances) != 0 {
+		t.Errorf("an alliance of one was not dissolved: %+v", game.Alliances)
+	}
+}
+
+func TestAllianceVictory(t *testing.T) {
+	game := newTestGame(t, func(r *Ruleset) {
+		r.NumAgents = 3
+		r.AllianceVictoryGold = 100
+	})
+
+	ally(t, game, 0, 1)
+
+	// An agent outside the alliance is rich enough alone, but only alliances win this way
+	game.Agents[0].Gold, game.Agents[1].Gold, game.Agents[2].Gold = 60, 39, 150
+	if alliance := game.winningAlliance(); alliance != nil {
+		t.Fatalf("alliance %d won with 99 gold", alliance.ID)
+	}
+
+	game.Agents[1].Gold = 40
+	alliance := game.winningAlliance()
+	if alliance == nil || !slices.Equal(alliance.Members, []int{0, 1}) {
+		t.Fatalf("winning alliance is %+v, want members [0 1]", alliance)
+	}
+
+	game.Rules.AllianceVictoryGold = 0
+	if alliance := game.winningAlliance(); alliance != nil {
+		t.Errorf("alliance %d won with team victory disabled", alliance.ID)
+	}
+}
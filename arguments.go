@@ -0,0 +1,499 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"slices"
+	"sort"
+	"strings"
+
+	openai "github.com/sashabaranov/go-openai"
+	"github.com/sashabaranov/go-openai/jsonschema"
+)
+
+// Typed arguments for each tool, decoded once the call has been validated against the tool's schema
+
+type giveResourcesArgs struct {
+	TargetAgent int      `json:"target_agent"`
+	Resource    Resource `json:"resource"`
+}
+
+type promiseArgs struct {
+	TargetAgent   int      `json:"target_agent"`
+	Resource      Resource `json:"resource"`
+	DeadlineRound int      `json:"deadline_round"`
+}
+
+type sendMessageArgs struct {
+	TargetAgent int    `json:"target_agent"`
+	Message     string `json:"message"`
+}
+
+type buildingArgs struct {
+	BuildingType string `json:"building_type"`
+}
+
+type countArgs struct {
+	Count int `json:"count"`
+}
+
+type raidArgs struct {
+	TargetAgent int `json:"target_agent"`
+	Workers     int `json:"workers"`
+}
+
+type sabotageArgs struct {
+	TargetAgent  int    `json:"target_agent"`
+	BuildingType string `json:"building_type"`
+}
+
+type targetArgs struct {
+	TargetAgent int `json:"target_agent"`
+}
+
+type acceptAllianceArgs struct {
+	FromAgent int `json:"from_agent"`
+}
+
+type messageArgs struct {
+	Message string `json:"message"`
+}
+
+type groupChatArgs struct {
+	Name    string `json:"name"`
+	Members []int  `json:"members"`
+}
+
+type inviteArgs struct {
+	ChannelID   int `json:"channel_id"`
+	TargetAgent int `json:"target_agent"`
+}
+
+type postArgs struct {
+	ChannelID int    `json:"channel_id"`
+	Message   string `json:"message"`
+}
+
+type proposePolicyArgs struct {
+	Kind         string  `json:"kind"`
+	Value        float64 `json:"value"`
+	BuildingType string  `json:"building_type"`
+}
+
+type noteArgs struct {
+	Note string `json:"note"`
+}
+
+type historyArgs struct {
+	Turns int `json:"turns"`
+}
+
+type voteArgs struct {
+	PolicyID int  `json:"policy_id"`
+	Support  bool `json:"support"`
+}
+
+// parseAction checks a tool call is well formed and makes sense before anything is changed,
+// returning the function that carries it out. Any error here means the call itself was invalid.
+func (a *Agent) parseAction(g *Game, toolCall openai.ToolCall) (func() error, error) {
+	raw, err := g.validateToolCall(toolCall)
+	if err != nil {
+		return nil, err
+	}
+
+	switch toolCall.Function.Name {
+	case "give_resources":
+		var args giveResourcesArgs
+		if err := decodeArguments(raw, &args); err != nil {
+			return nil, err
+		}
+
+		if args.Resource.Amount <= 0 {
+			return nil, actionError(ErrInvalidArguments, "resource amount must be greater than 0")
+		}
+
+		if err := a.checkTarget(g, args.TargetAgent); err != nil {
+			return nil, err
+		}
+
+		return func() error { return a.GiveResource(g, args.TargetAgent, args.Resource) }, nil
+	case "send_message":
+		var args sendMessageArgs
+		if err := decodeArguments(raw, &args); err != nil {
+			return nil, err
+		}
+
+		if err := a.checkTarget(g, args.TargetAgent); err != nil {
+			return nil, err
+		}
+
+		return func() error { return a.SendMessage(g, args.TargetAgent, args.Message) }, nil
+	case "make_promise":
+		var args promiseArgs
+		if err := decodeArguments(raw, &args); err != nil {
+			return nil, err
+		}
+
+		if args.Resource.Amount <= 0 {
+			return nil, actionError(ErrInvalidArguments, "resource amount must be greater than 0")
+		}
+
+		if args.DeadlineRound <= g.CurrentTurn {
+			return nil, actionError(ErrInvalidArguments, "the deadline must be this round (%d) or a later one", g.CurrentTurn+1)
+		}
+
+		if err := a.checkTarget(g, args.TargetAgent); err != nil {
+			return nil, err
+		}
+
+		return func() error { return a.MakePromise(g, args.TargetAgent, args.Resource, args.DeadlineRound) }, nil
+	case "broadcast_message":
+		var args messageArgs
+		if err := decodeArguments(raw, &args); err != nil {
+			return nil, err
+		}
+
+		return func() error { return a.Broadcast(g, args.Message) }, nil
+	case "create_group_chat":
+		var args groupChatArgs
+		if err := decodeArguments(raw, &args); err != nil {
+			return nil, err
+		}
+
+		if strings.TrimSpace(args.Name) == "" {
+			return nil, actionError(ErrInvalidArguments, "group chat name cannot be empty")
+		}
+
+		members := slices.Clone(args.Members)
+		slices.Sort(members)
+		members = slices.Compact(members)
+		if len(members) == 0 {
+			return nil, actionError(ErrInvalidArguments, "a group chat needs at least one other member")
+		}
+
+		for _, member := range members {
+			if err := a.checkTarget(g, member); err != nil {
+				return nil, err
+			}
+		}
+
+		return func() error { return a.CreateGroupChat(g, args.Name, members) }, nil
+	case "invite_to_channel":
+		var args inviteArgs
+		if err := decodeArguments(raw, &args); err != nil {
+			return nil, err
+		}
+
+		if err := a.checkTarget(g, args.TargetAgent); err != nil {
+			return nil, err
+		}
+
+		return func() error { return a.InviteToChannel(g, args.ChannelID, args.TargetAgent) }, nil
+	case "post_to_channel":
+		var args postArgs
+		if err := decodeArguments(raw, &args); err != nil {
+			return nil, err
+		}
+
+		return func() error { return a.PostToChannel(g, args.ChannelID, args.Message) }, nil
+	case "list_channels":
+		return func() error { return a.ListChannels(g) }, nil
+	case "buy_building", "man_building", "unman_building":
+		var args buildingArgs
+		if err := decodeArguments(raw, &args); err != nil {
+			return nil, err
+		}
+
+		switch toolCall.Function.Name {
+		case "man_building":
+			return func() error { return a.ManBuilding(g, args.BuildingType) }, nil
+		case "unman_building":
+			return func() error { return a.UnmanBuilding(g, args.BuildingType) }, nil
+		}
+
+		return func() error { return a.BuyBuilding(g, args.BuildingType) }, nil
+	case "buy_worker", "hire_guards":
+		var args countArgs
+		if err := decodeArguments(raw, &args); err != nil {
+			return nil, err
+		}
+
+		if args.Count <= 0 {
+			return nil, actionError(ErrInvalidArguments, "count must be greater than 0")
+		}
+
+		if toolCall.Function.Name == "hire_guards" {
+			return func() error { return a.HireGuards(g, args.Count) }, nil
+		}
+
+		return func() error { return a.BuyWorkers(g, args.Count) }, nil
+	case "raid":
+		var args raidArgs
+		if err := decodeArguments(raw, &args); err != nil {
+			return nil, err
+		}
+
+		if args.Workers <= 0 {
+			return nil, actionError(ErrInvalidArguments, "raid must commit at least one worker")
+		}
+
+		if err := a.checkTarget(g, args.TargetAgent); err != nil {
+			return nil, err
+		}
+
+		return func() error { return a.Raid(g, args.TargetAgent, args.Workers) }, nil
+	case "sabotage_building":
+		var args sabotageArgs
+		if err := decodeArguments(raw, &args); err != nil {
+			return nil, err
+		}
+
+		if err := a.checkTarget(g, args.TargetAgent); err != nil {
+			return nil, err
+		}
+
+		return func() error { return a.SabotageBuilding(g, args.TargetAgent, args.BuildingType) }, nil
+	case "build_wall":
+		return func() error { return a.BuildWall(g) }, nil
+	case "propose_alliance":
+		var args targetArgs
+		if err := decodeArguments(raw, &args); err != nil {
+			return nil, err
+		}
+
+		if err := a.checkTarget(g, args.TargetAgent); err != nil {
+			return nil, err
+		}
+
+		return func() error { return a.ProposeAlliance(g, args.TargetAgent) }, nil
+	case "accept_alliance":
+		var args acceptAllianceArgs
+		if err := decodeArguments(raw, &args); err != nil {
+			return nil, err
+		}
+
+		if err := a.checkTarget(g, args.FromAgent); err != nil {
+			return nil, err
+		}
+
+		return func() error { return a.AcceptAlliance(g, args.FromAgent) }, nil
+	case "leave_alliance":
+		return func() error { return a.LeaveAlliance(g) }, nil
+	case "send_alliance_message":
+		var args messageArgs
+		if err := decodeArguments(raw, &args); err != nil {
+			return nil, err
+		}
+
+		return func() error { return a.SendAllianceMessage(g, args.Message) }, nil
+	case "propose_policy":
+		var args proposePolicyArgs
+		if err := decodeArguments(raw, &args); err != nil {
+			return nil, err
+		}
+
+		return func() error { return a.ProposePolicy(g, args.Kind, args.Value, args.BuildingType) }, nil
+	case "vote":
+		var args voteArgs
+		if err := decodeArguments(raw, &args); err != nil {
+			return nil, err
+		}
+
+		return func() error { return a.Vote(g, args.PolicyID, args.Support) }, nil
+	case "write_note":
+		var args noteArgs
+		if err := decodeArguments(raw, &args); err != nil {
+			return nil, err
+		}
+
+		if strings.TrimSpace(args.Note) == "" {
+			return nil, actionError(ErrInvalidArguments, "note cannot be empty")
+		}
+
+		return func() error { return a.WriteNote(g, args.Note) }, nil
+	case "read_notes":
+		return func() error { return a.ReadNotes(g) }, nil
+	case "inspect_agent":
+		var args targetArgs
+		if err := decodeArguments(raw, &args); err != nil {
+			return nil, err
+		}
+
+		if err := a.checkTarget(g, args.TargetAgent); err != nil {
+			return nil, err
+		}
+
+		return func() error { return a.InspectAgent(g, args.TargetAgent) }, nil
+	case "list_buildings":
+		return func() error { return a.ListBuildings(g) }, nil
+	case "view_price_history", "view_my_ledger":
+		var args historyArgs
+		if err := decodeArguments(raw, &args); err != nil {
+			return nil, err
+		}
+
+		if args.Turns < 0 {
+			return nil, actionError(ErrInvalidArguments, "turns cannot be negative")
+		}
+
+		if toolCall.Function.Name == "view_my_ledger" {
+			return func() error { return a.ViewLedger(g, args.Turns) }, nil
+		}
+
+		return func() error { return a.ViewPriceHistory(g, args.Turns) }, nil
+	case "end_turn":
+		return func() error {
+			a.AddTurnLog("Ending turn early")
+			return nil
+		}, nil
+	}
+
+	return nil, actionError(ErrUnknownAction, "Unknown tool name: %s", toolCall.Function.Name)
+}
+
+// checkTarget makes sure an agent ID refers to another agent still in the game
+func (a *Agent) checkTarget(g *Game, agentID int) error {
+	if agentID < 0 || agentID >= len(g.Agents) {
+		return actionError(ErrInvalidTarget, "Agent %d does not exist", agentID)
+	}
+
+	if agentID == a.ID {
+		return actionError(ErrInvalidTarget, "you cannot target yourself")
+	}
+
+	if g.Agents[agentID].Lost {
+		return actionError(ErrTargetEliminated, "Agent %d has been eliminated", agentID)
+	}
+
+	return nil
+}
+
+// validateToolCall checks the call against the definition of a tool offered this game, returning
+// its arguments re-encoded so whole numbers such as 2.0 decode into integer fields
+func (g *Game) validateToolCall(toolCall openai.ToolCall) ([]byte, error) {
+	i := slices.IndexFunc(g.tools, func(tool openai.Tool) bool {
+		return tool.Function != nil && tool.Function.Name == toolCall.Function.Name
+	})
+	if i == -1 {
+		return nil, actionError(ErrUnknownAction, "Unknown tool name: %s", toolCall.Function.Name)
+	}
+
+	argsString := toolCall.Function.Arguments
+	if argsString == "" {
+		argsString = "{}"
+	}
+
+	var args any
+	if err := json.Unmarshal([]byte(argsString), &args); err != nil {
+		return nil, actionError(ErrInvalidArguments, "arguments are not valid JSON: %s", err)
+	}
+
+	if schema, ok := g.tools[i].Function.Parameters.(jsonschema.Definition); ok {
+		if err := validateSchema(schema, args, ""); err != nil {
+			return nil, actionError(ErrInvalidArguments, "%s", err)
+		}
+	}
+
+	raw, err := json.Marshal(args)
+	if err != nil {
+		return nil, actionError(ErrInvalidArguments, "failed to encode arguments: %s", err)
+	}
+
+	return raw, nil
+}
+
+// validateSchema checks a decoded JSON value against the subset of JSON schema used by the tool
+// definitions: types, required properties and enums
+func validateSchema(schema jsonschema.Definition, value any, path string) error {
+	name := path
+	if name == "" {
+		name = "arguments"
+	}
+
+	switch schema.Type {
+	case jsonschema.Object:
+		object, ok := value.(map[string]any)
+		if !ok {
+			return fmt.Errorf("%s must be an object", name)
+		}
+
+		for _, property := range schema.Required {
+			if _, ok := object[property]; !ok {
+				return fmt.Errorf("missing required argument %s", joinPath(path, property))
+			}
+		}
+
+		// Check properties in a fixed order so the same call always reports the same error
+		properties := make([]string, 0, len(object))
+		for property := range object {
+			properties = append(properties, property)
+		}
+		sort.Strings(properties)
+
+		for _, property := range properties {
+			definition, ok := schema.Properties[property]
+			if !ok {
+				continue
+			}
+
+			if err := validateSchema(definition, object[property], joinPath(path, property)); err != nil {
+				return err
+			}
+		}
+	case jsonschema.Array:
+		items, ok := value.([]any)
+		if !ok {
+			return fmt.Errorf("%s must be an array", name)
+		}
+
+		if schema.Items != nil {
+			for i, item := range items {
+				if err := validateSchema(*schema.Items, item, fmt.Sprintf("%s[%d]", name, i)); err != nil {
+					return err
+				}
+			}
+		}
+	case jsonschema.String:
+		s, ok := value.(string)
+		if !ok {
+			return fmt.Errorf("%s must be a string", name)
+		}
+
+		if len(schema.Enum) > 0 && !slices.Contains(schema.Enum, s) {
+			return fmt.Errorf("%s must be one of %v, got %q", name, schema.Enum, s)
+		}
+	case jsonschema.Integer:
+		n, ok := value.(float64)
+		if !ok || n != math.Trunc(n) {
+			return fmt.Errorf("%s must be an integer", name)
+		}
+	case jsonschema.Number:
+		if _, ok := value.(float64); !ok {
+			return fmt.Errorf("%s must be a number", name)
+		}
+	case jsonschema.Boolean:
+		if _, ok := value.(bool); !ok {
+			return fmt.Errorf("%s must be true or false", name)
+		}
+	}
+
+	return nil
+}
+
+func joinPath(path string, property string) string {
+	if path == "" {
+		return property
+	}
+
+	return path + "." + property
+}
+
+// decodeArguments reads validated arguments into a tool's argument struct
+func decodeArguments(raw []byte, args any) error {
+	if err := json.Unmarshal(raw, args); err != nil {
+		return actionError(ErrInvalidArguments, "failed to decode arguments: %s", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"encoding/json"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/sashabaranov/go-openai/jsonschema"
+)
+
+func TestValidateSchema(t *testing.T) {
+	schema := jsonschema.Definition{
+		Type: jsonschema.Object,
+		Properties: map[string]jsonschema.Definition{
+			"target_agent": {Type: jsonschema.Integer},
+			"resource": {
+				Type: jsonschema.Object,
+				Properties: map[string]jsonschema.Definition{
+					"type":   {Type: jsonschema.String, Enum: []string{"Gold", "Wheat"}},
+					"amount": {Type: jsonschema.Integer},
+				},
+				Required: []string{"type", "amount"},
+			},
+			"members":  {Type: jsonschema.Array, Items: &jsonschema.Definition{Type: jsonschema.Integer}},
+			"ratio":    {Type: jsonschema.Number},
+			"in_favor": {Type: jsonschema.Boolean},
+		},
+		Required: []string{"target_agent"},
+	}
+
+	tests := []struct {
+		name string
+		args string
+		// err is part of the expected error, or empty if the arguments are valid
+		err string
+	}{
+		{"minimal", `{"target_agent": 1}`, ""},
+		{"every argument", `{"target_agent": 1, "resource": {"type": "Gold", "amount": 5}, "members": [0, 2], "ratio": 0.5, "in_favor": true}`, ""},
+		{"unknown arguments are ignored", `{"target_agent": 1, "reason": "because"}`, ""},
+		{"whole float is an integer", `{"target_agent": 1.0}`, ""},
+		{"not an object", `[1]`, "arguments must be an object"},
+		{"missing required", `{}`, "missing required argument target_agent"},
+		{"missing nested required", `{"target_agent": 1, "resource": {"type": "Gold"}}`, "missing required argument resource.amount"},
+		{"string for integer", `{"target_agent": "1"}`, "target_agent must be an integer"},
+		{"fractional integer", `{"target_agent": 1.5}`, "target_agent must be an integer"},
+		{"enum", `{"target_agent": 1, "resource": {"type": "Silver", "amount": 5}}`, `resource.type must be one of [Gold Wheat], got "Silver"`},
+		{"array item", `{"target_agent": 1, "members": [0, "two"]}`, "members[1] must be an integer"},
+		{"not an array", `{"target_agent": 1, "members": 2}`, "members must be an array"},
+		{"number", `{"target_agent": 1, "ratio": "half"}`, "ratio must be a number"},
+		{"boolean", `{"target_agent": 1, "in_favor": "yes"}`, "in_favor must be true or false"},
+		{"first property reported first", `{"target_agent": "1", "ratio": "half"}`, "ratio must be a number"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var value any
+			if err := json.Unmarshal([]byte(tt.args), &value); err != nil {
+				t.Fatal(err)
+			}
+
+			err := validateSchema(schema, value, "")
+			if tt.err == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Fatalf("got error %v, want %q", err, tt.err)
+			}
+		})
+	}
+}
+
+// TestPurchasesCannotOverflow buys counts so large that multiplying out their cost would wrap
+// around to a price the agent can afford
+func TestPurchasesCannotOverflow(t *testing.T) {
+	game := newTestGame(t, nil)
+	agent := &game.Agents[0]
+	gold := agent.Gold
+
+	huge := math.MaxInt/WorkerCost + 1
+
+	if err := agent.BuyWorkers(game, huge); errorCode(err) != ErrInsufficientGold {
+		t.Errorf("buying %d workers returned %v, want %s", huge, err, ErrInsufficientGold)
+	}
+
+	if err := agent.HireGuards(game, huge); errorCode(err) != ErrInsufficientGold {
+		t.Errorf("hiring %d guards returned %v, want %s", huge, err, ErrInsufficientGold)
+	}
+
+	if agent.Gold != gold || agent.Workers != StartingWorkers || agent.Guards != 0 {
+		t.Errorf("agent has %d gold, %d workers and %d guards after failed purchases", agent.Gold, agent.Workers, agent.Guards)
+	}
+
+	if !canAfford(gold, gold/WorkerCost, WorkerCost) || canAfford(gold, gold/WorkerCost+1, WorkerCost) {
+		t.Errorf("canAfford disagrees with %d gold at %d each", gold, WorkerCost)
+	}
+}
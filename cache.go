@@ -0,0 +1,163 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+// Cache modes
+const (
+	// CacheRecord always calls the model and stores every response
+	CacheRecord = "record"
+	// CacheReplay answers only from the cache, and fails on a miss
+	CacheReplay = "replay"
+	// CacheRecordMissing answers from the cache, calling the model and storing the response on a miss
+	CacheRecordMissing = "record-missing"
+)
+
+// ErrCacheMiss is returned in replay mode for a request that has no cached response
+var ErrCacheMiss = errors.New("no cached response for request")
+
+// CachingClient stores model responses on disk, keyed by the request, so games can be re-run
+// offline and for free
+type CachingClient struct {
+	client ChatClient
+	dir    string
+	mode   string
+}
+
+// NewCachingClient wraps a client with a response cache in dir. The client may be nil in replay mode.
+func NewCachingClient(client ChatClient, dir string, mode string) (*CachingClient, error) {
+	switch mode {
+	case CacheRecord, CacheRecordMissing:
+		if client == nil {
+			return nil, fmt.Errorf("cache mode %s needs a model client", mode)
+		}
+	case CacheReplay:
+	default:
+		return nil, fmt.Errorf("unknown cache mode %q", mode)
+	}
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create cache directory: %w", err)
+	}
+
+	return &CachingClient{client: client, dir: dir, mode: mode}, nil
+}
+
+func (c *CachingClient) CreateChatCompletion(ctx context.Context, request openai.ChatCompletionRequest) (openai.ChatCompletionResponse, error) {
+	key, err := cacheKey(request)
+	if err != nil {
+		return openai.ChatCompletionResponse{}, err
+	}
+
+	if c.mode != CacheRecord {
+		resp, err := c.load(key)
+		if err == nil {
+			return resp, nil
+		}
+
+		if !errors.Is(err, ErrCacheMiss) {
+			return resp, err
+		}
+
+		if c.mode == CacheReplay {
+			return resp, fmt.Errorf("%w %s", ErrCacheMiss, key)
+		}
+	}
+
+	resp, err := c.client.CreateChatCompletion(ctx, request)
+	if err != nil {
+		return resp, err
+	}
+
+	if err := c.store(key, resp); err != nil {
+		return resp, err
+	}
+
+	return resp, nil
+}
+
+// cacheKey hashes the parts of a request that determine the model's answer
+func cacheKey(request openai.ChatCompletionRequest) (string, error) {
+	data, err := json.Marshal(struct {
+		Model          string
+		Messages       []openai.ChatCompletionMessage
+		Tools          []openai.Tool
+		ToolChoice     any
+		ResponseFormat *openai.ChatCompletionResponseFormat `json:",omitempty"`
+	}{
+		Model:          request.Model,
+		Messages:       request.Messages,
+		Tools:          request.Tools,
+		ToolChoice:     request.ToolChoice,
+		ResponseFormat: request.ResponseFormat,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	sum := sha256.Sum256(data)
+
+	return hex.EncodeToString(sum[:]), nil
+}
+
+func (c *CachingClient) path(key string) string {
+	return filepath.Join(c.dir, key+".json")
+}
+
+func (c *CachingClient) load(key string) (openai.ChatCompletionResponse, error) {
+	var resp openai.ChatCompletionResponse
+
+	data, err := os.ReadFile(c.path(key))
+	if errors.Is(err, os.ErrNotExist) {
+		return resp, ErrCacheMiss
+	}
+	if err != nil {
+		return resp, fmt.Errorf("failed to read cached response: %w", err)
+	}
+
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return resp, fmt.Errorf("failed to parse cached response %s: %w", key, err)
+	}
+
+	return resp, nil
+}
+
+// store writes the response to a temporary file and renames it into place, so agents calling
+// concurrently never see a partly written entry
+func (c *CachingClient) store(key string, resp openai.ChatCompletionResponse) error {
+	data, err := json.MarshalIndent(resp, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal response: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(c.dir, key+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to cache response: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to cache response: %w", err)
+	}
+
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to cache response: %w", err)
+	}
+
+	if err := os.Rename(tmp.Name(), c.path(key)); err != nil {
+		return fmt.Errorf("failed to cache response: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+func cacheRequest(content string) openai.ChatCompletionRequest {
+	return openai.ChatCompletionRequest{
+		Model:    openai.GPT4o,
+		Messages: []openai.ChatCompletionMessage{{Role: openai.ChatMessageRoleUser, Content: content}},
+	}
+}
+
+// TestCachingClientReplaysRecordedResponses records a response, then replays it without the model
+func TestCachingClientReplaysRecordedResponses(t *testing.T) {
+	dir := t.TempDir()
+	model := &stubClient{}
+
+	recorder, err := NewCachingClient(model, dir, CacheRecord)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	recorded, err := recorder.CreateChatCompletion(context.Background(), cacheRequest("hello"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Record mode calls the model even when the response is already cached
+	if _, err := recorder.CreateChatCompletion(context.Background(), cacheRequest("hello")); err != nil {
+		t.Fatal(err)
+	}
+	if model.calls != 2 {
+		t.Fatalf("record mode called the model %d times, want 2", model.calls)
+	}
+
+	replayer, err := NewCachingClient(nil, dir, CacheReplay)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	replayed, err := replayer.CreateChatCompletion(context.Background(), cacheRequest("hello"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if replayed.Choices[0].Message.Content != recorded.Choices[0].Message.Content {
+		t.Errorf("replayed %q, want %q", replayed.Choices[0].Message.Content, recorded.Choices[0].Message.Content)
+	}
+	if replayed.Usage != recorded.Usage {
+		t.Errorf("replayed usage %+v, want %+v", replayed.Usage, recorded.Usage)
+	}
+}
+
+func TestCachingClientReplayMiss(t *testing.T) {
+	replayer, err := NewCachingClient(nil, t.TempDir(), CacheReplay)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = replayer.CreateChatCompletion(context.Background(), cacheRequest("hello"))
+	if !errors.Is(err, ErrCacheMiss) {
+		t.Fatalf("got error %v, want %v", err, ErrCacheMiss)
+	}
+}
+
+// TestCachingClientRecordsMissing only calls the model for requests that aren't cached
+func TestCachingClientRecordsMissing(t *testing.T) {
+	dir := t.TempDir()
+	model := &stubClient{}
+
+	client, err := NewCachingClient(model, dir, CacheRecordMissing)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, content := range []string{"hello", "hello", "goodbye", "hello"} {
+		if _, err := client.CreateChatCompletion(context.Background(), cacheRequest(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if model.calls != 2 {
+		t.Fatalf("called the model %d times, want 2", model.calls)
+	}
+
+	replayer, err := NewCachingClient(nil, dir, CacheReplay)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, content := range []string{"hello", "goodbye"} {
+		if _, err := replayer.CreateChatCompletion(context.Background(), cacheRequest(content)); err != nil {
+			t.Errorf("replaying %q: %v", content, err)
+		}
+	}
+}
+
+func TestNewCachingClientChecksMode(t *testing.T) {
+	if _, err := NewCachingClient(nil, t.TempDir(), CacheRecord); err == nil {
+		t.Error("record mode accepted a nil client")
+	}
+
+	if _, err := NewCachingClient(&stubClient{}, t.TempDir(), "rewind"); err == nil {
+		t.Error("accepted an unknown cache mode")
+	}
+}
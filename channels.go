@@ -0,0 +1,250 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// ChannelEvent is the event kind recorded when group chats are created or agents invited to them
+const ChannelEvent = "channel"
+
+// Channel kinds
+const (
+	// TownSquareChannel is the public channel every agent can read and post to
+	TownSquareChannel = "town_square"
+	// GroupChannel is a group chat created by an agent, which its members can invite others to
+	GroupChannel = "group"
+	// AllianceChannel is an alliance's private chat, whose members are the alliance's members
+	AllianceChannel = "alliance"
+	// DirectChannel holds the messages sent between two agents with send_message
+	DirectChannel = "direct"
+)
+
+// TownSquare is the ID of the town square channel
+const TownSquare = 0
+
+// Channel is a chat that its members can read and post messages to
+type Channel struct {
+	ID       int
+	Name     string
+	Kind     string
+	Members  []int
+	Messages []ChatMessage
+}
+
+// ChatMessage is a message an agent posted on a channel
+type ChatMessage struct {
+	Turn      int
+	ChannelID int
+	Channel   string
+	AgentID   int
+	Message   string
+}
+
+// newTownSquare creates the town square, which every agent belongs to
+func newTownSquare(numAgents int) Channel {
+	members := make([]int, numAgents)
+	for i := range members {
+		members[i] = i
+	}
+
+	return Channel{ID: TownSquare, Name: "town square", Kind: TownSquareChannel, Members: members}
+}
+
+// channel returns the channel with the given ID, or nil if there is none
+func (g *Game) channel(channelID int) *Channel {
+	if channelID < 0 || channelID >= len(g.Channels) {
+		return nil
+	}
+
+	return &g.Channels[channelID]
+}
+
+// createChannel opens a new channel with the given members
+func (g *Game) createChannel(name string, kind string, members []int) *Channel {
+	g.Channels = append(g.Channels, Channel{
+		ID:      len(g.Channels),
+		Name:    name,
+		Kind:    kind,
+		Members: members,
+	})
+
+	return &g.Channels[len(g.Channels)-1]
+}
+
+// directChannel returns the channel holding the messages between two agents, opening it the first
+// time they talk
+func (g *Game) directChannel(agentID int, otherID int) *Channel {
+	members := []int{min(agentID, otherID), max(agentID, otherID)}
+	for i := range g.Channels {
+		if g.Channels[i].Kind == DirectChannel && slices.Equal(g.Channels[i].Members, members) {
+			return &g.Channels[i]
+		}
+	}
+
+	return g.createChannel(fmt.Sprintf("Agents %d and %d", members[0], members[1]), DirectChannel, members)
+}
+
+// postToChannel records a message on a channel and delivers it to every member still in the game
+// except the sender
+func (g *Game) postToChannel(channel *Channel, fromAgentID int, message string) {
+	g.recordChatMessage(channel, fromAgentID, message)
+
+	notice := channelNotice(channel, fromAgentID, message)
+	for _, member := range channel.Members {
+		if member != fromAgentID && !g.Agents[member].Lost {
+			g.Agents[member].notify(notice)
+		}
+	}
+}
+
+// recordChatMessage adds a message to a channel's history, and queues it to be shown in the UI
+// with the next turn pushed
+func (g *Game) recordChatMessage(channel *Channel, fromAgentID int, message string) {
+	msg := ChatMessage{
+		Turn:      g.CurrentTurn,
+		ChannelID: channel.ID,
+		Channel:   channel.Name,
+		AgentID:   fromAgentID,
+		Message:   message,
+	}
+
+	channel.Messages = append(channel.Messages, msg)
+	g.unpushedMessages = append(g.unpushedMessages, msg)
+}
+
+// channelNotice is how a message on a channel appears to its members
+func channelNotice(channel *Channel, fromAgentID int, message string) string {
+	switch channel.Kind {
+	case TownSquareChannel:
+		return fmt.Sprintf("Agent %d has posted in the town square, which every agent can read. The message says: %s", fromAgentID, message)
+	case AllianceChannel:
+		return fmt.Sprintf("You have received a message on your private alliance channel from Agent %d! The message says: %s", fromAgentID, message)
+	case DirectChannel:
+		return fmt.Sprintf("You have received a message from Agent %d! The message says: %s", fromAgentID, message)
+	}
+
+	return fmt.Sprintf("You have received a message from Agent %d in group chat %d (%s)! The message says: %s", fromAgentID, channel.ID, channel.Name, message)
+}
+
+// memberChannel returns a channel the agent belongs to, or an error saying why they can't use it
+func (a *Agent) memberChannel(g *Game, channelID int) (*Channel, error) {
+	channel := g.channel(channelID)
+	if channel == nil {
+		return nil, actionError(ErrNoSuchChannel, "there is no channel %d", channelID)
+	}
+
+	if !slices.Contains(channel.Members, a.ID) {
+		return nil, actionError(ErrNoSuchChannel, "you are not a member of channel %d", channelID)
+	}
+
+	return channel, nil
+}
+
+// Broadcast posts a message in the town square, where every agent will read it
+func (a *Agent) Broadcast(g *Game, message string) error {
+	g.postToChannel(&g.Channels[TownSquare], a.ID, message)
+	g.detectDeception(a, "broadcast_message", message)
+	a.AddTurnLog("Posted a message in the town square")
+
+	return nil
+}
+
+// PostToChannel posts a message on a channel the agent belongs to
+func (a *Agent) PostToChannel(g *Game, channelID int, message string) error {
+	channel, err := a.memberChannel(g, channelID)
+	if err != nil {
+		return fmt.Errorf("Failed to post to channel %d: %w", channelID, err)
+	}
+
+	g.postToChannel(channel, a.ID, message)
+	g.detectDeception(a, "post_to_channel", message)
+	a.AddTurnLog(fmt.Sprintf("Posted a message on channel %d (%s)", channel.ID, channel.Name))
+
+	return nil
+}
+
+// CreateGroupChat opens a group chat between the agent and the given agents
+func (a *Agent) CreateGroupChat(g *Game, name string, members []int) error {
+	a.AddTurnLog(fmt.Sprintf("Attempting to create group chat %q with Agents %s", name, joinAgentIDs(members)))
+
+	members = slices.Clone(members)
+	slices.Sort(members)
+	members = slices.Compact(members)
+	if len(members) == 0 {
+		return actionError(ErrInvalidArguments, "Failed to create group chat, it needs at least one other member")
+	}
+
+	for _, member := range members {
+		if err := a.checkTarget(g, member); err != nil {
+			return fmt.Errorf("Failed to create group chat: %w", err)
+		}
+	}
+
+	channel := g.createChannel(name, GroupChannel, append([]int{a.ID}, members...))
+
+	msg := fmt.Sprintf("Agent %d has created group chat %d (%s) with Agents %s", a.ID, channel.ID, channel.Name, joinAgentIDs(members))
+	a.AddTurnLog(fmt.Sprintf("Created group chat %d (%s). Use post_to_channel with channel_id %d to talk in it.", channel.ID, channel.Name, channel.ID))
+	for _, member := range members {
+		g.Agents[member].notify(fmt.Sprintf("%s. Use post_to_channel with channel_id %d to talk in it.", msg, channel.ID))
+	}
+	g.recordEvent(ChannelEvent, a.ID, -1, true, msg)
+
+	return nil
+}
+
+// InviteToChannel adds another agent to a group chat the agent belongs to
+func (a *Agent) InviteToChannel(g *Game, channelID int, targetAgent int) error {
+	a.AddTurnLog(fmt.Sprintf("Attempting to invite Agent %d to channel %d", targetAgent, channelID))
+
+	if err := a.checkTarget(g, targetAgent); err != nil {
+		return fmt.Errorf("Failed to invite Agent %d: %w", targetAgent, err)
+	}
+
+	channel, err := a.memberChannel(g, channelID)
+	if err != nil {
+		return fmt.Errorf("Failed to invite Agent %d: %w", targetAgent, err)
+	}
+
+	if channel.Kind != GroupChannel {
+		return actionError(ErrNoSuchChannel, "Failed to invite Agent %d, only group chats take invitations", targetAgent)
+	}
+
+	if slices.Contains(channel.Members, targetAgent) {
+		return actionError(ErrInvalidTarget, "Failed to invite Agent %d, they are already in channel %d", targetAgent, channelID)
+	}
+
+	channel.Members = append(channel.Members, targetAgent)
+
+	msg := fmt.Sprintf("Agent %d has added Agent %d to group chat %d (%s)", a.ID, targetAgent, channel.ID, channel.Name)
+	a.AddTurnLog(msg)
+	for _, member := range channel.Members {
+		if member != a.ID && member != targetAgent {
+			g.Agents[member].notify(msg)
+		}
+	}
+	g.Agents[targetAgent].notify(fmt.Sprintf("Agent %d has added you to group chat %d (%s) with Agents %s. Use post_to_channel with channel_id %d to talk in it.",
+		a.ID, channel.ID, channel.Name, joinAgentIDs(channel.Members[:len(channel.Members)-1]), channel.ID))
+	g.recordEvent(ChannelEvent, a.ID, targetAgent, true, msg)
+
+	return nil
+}
+
+// ListChannels shows the agent every channel they belong to and who else is in it
+func (a *Agent) ListChannels(g *Game) error {
+	lines := []string{}
+	for i := range g.Channels {
+		channel := &g.Channels[i]
+		if !slices.Contains(channel.Members, a.ID) {
+			continue
+		}
+
+		lines = append(lines, fmt.Sprintf("- Channel %d (%s): Agents %s, %d messages",
+			channel.ID, channel.Name, joinAgentIDs(channel.Members), len(channel.Messages)))
+	}
+
+	a.AddTurnLog("Your channels:\n" + strings.Join(lines, "\n"))
+
+	return nil
+}
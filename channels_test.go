@@ -0,0 +1,106 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func lastMessage(agent *Agent) string {
+	return agent.Prompt[len(agent.Prompt)-1].Content
+}
+
+func TestDirectChannelPerPair(t *testing.T) {
+	game := newTestGame(t, func(r *Ruleset) { r.NumAgents = 3 })
+
+	first := game.directChannel(2, 0)
+	if second := game.directChannel(0, 2); second.ID != first.ID {
+		t.Fatalf("Agents 0 and 2 have channels %d and %d", first.ID, second.ID)
+	}
+	if !slices.Equal(first.Members, []int{0, 2}) {
+		t.Errorf("direct channel members are %v", first.Members)
+	}
+
+	channels := len(game.Channels)
+	for _, pair := range [][2]int{{0, 2}, {2, 0}, {0, 2}} {
+		if err := game.Agents[pair[0]].SendMessage(game, pair[1], "hello"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if len(game.Channels) != channels {
+		t.Errorf("sending messages opened %d more channels", len(game.Channels)-channels)
+	}
+	if got := len(game.channel(first.ID).Messages); got != 3 {
+		t.Errorf("direct channel holds %d messages, want 3", got)
+	}
+
+	// Another pair gets their own channel
+	if err := game.Agents[1].SendMessage(game, 0, "hello"); err != nil {
+		t.Fatal(err)
+	}
+	if len(game.Channels) != channels+1 {
+		t.Errorf("Agents 0 and 1 talking opened %d channels, want 1", len(game.Channels)-channels)
+	}
+	if got := lastMessage(&game.Agents[0]); !strings.Contains(got, "You have received a message from Agent 1") {
+		t.Errorf("Agent 0 was told %q", got)
+	}
+}
+
+func TestBroadcastsAreNotChannelHistory(t *testing.T) {
+	game := newTestGame(t, func(r *Ruleset) { r.NumAgents = 3 })
+
+	game.broadcastMessage("Agent 1 has betrayed their alliance", 1)
+
+	for i := range game.Channels {
+		if len(game.Channels[i].Messages) != 0 {
+			t.Errorf("channel %d holds the announcement: %+v", game.Channels[i].ID, game.Channels[i].Messages)
+		}
+	}
+	if got := lastMessage(&game.Agents[0]); !strings.Contains(got, "has betrayed") {
+		t.Errorf("Agent 0 was told %q", got)
+	}
+
+	// Agents posting in the town square are kept in its history
+	if err := game.Agents[1].Broadcast(game, "Wheat for sale"); err != nil {
+		t.Fatal(err)
+	}
+	if messages := game.Channels[TownSquare].Messages; len(messages) != 1 || messages[0].AgentID != 1 {
+		t.Errorf("town square holds %+v", messages)
+	}
+}
+
+func TestCreateGroupChat(t *testing.T) {
+	game := newTestGame(t, func(r *Ruleset) { r.NumAgents = 4 })
+	agent := &game.Agents[0]
+
+	if err := agent.CreateGroupChat(game, "traders", []int{3, 1, 3}); err != nil {
+		t.Fatal(err)
+	}
+
+	channel := &game.Channels[len(game.Channels)-1]
+	if !slices.Equal(channel.Members, []int{0, 1, 3}) {
+		t.Errorf("group chat members are %v, want [0 1 3]", channel.Members)
+	}
+	if got := lastMessage(&game.Agents[3]); !strings.Contains(got, "has created group chat") {
+		t.Errorf("Agent 3 was told %q", got)
+	}
+
+	if err := agent.CreateGroupChat(game, "empty", nil); errorCode(err) != ErrInvalidArguments {
+		t.Errorf("creating an empty group chat: %v", err)
+	}
+
+	if err := game.Agents[2].PostToChannel(game, channel.ID, "let me in"); errorCode(err) != ErrNoSuchChannel {
+		t.Errorf("posting to a channel Agent 2 isn't in: %v", err)
+	}
+
+	if err := game.Agents[1].InviteToChannel(game, channel.ID, 2); err != nil {
+		t.Fatal(err)
+	}
+	if err := game.Agents[2].PostToChannel(game, channel.ID, "thanks"); err != nil {
+		t.Errorf("posting after being invited: %v", err)
+	}
+	if got := lastMessage(agent); !strings.Contains(got, "The message says: thanks") {
+		t.Errorf("Agent 0 was told %q", got)
+	}
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"fmt"
+	"math"
+)
+
+// Event kinds
+const (
+	RaidEvent     = "raid"
+	SabotageEvent = "sabotage"
+)
+
+// defence is how much the agent's walls and guards reduce the odds of a raid
+// or sabotage against them
+func (a *Agent) defence(g *Game) float64 {
+	d := float64(a.Walls)*g.Rules.WallDefence + float64(a.Guards)*g.Rules.GuardDefence
+
+	return math.Min(d, g.Rules.MaxDefence)
+}
+
+// attackTarget looks up the agent being attacked, returning an error if they cannot be attacked
+func (a *Agent) attackTarget(g *Game, targetAgent int) (*Agent, error) {
+	if err := a.checkTarget(g, targetAgent); err != nil {
+		return nil, err
+	}
+
+	return &g.Agents[targetAgent], nil
+}
+
+// Raid commits workers and gold to try and steal a share of the target's gold and wheat.
+// If the raid fails, half of the committed workers (rounded up) are lost.
+func (a *Agent) Raid(g *Game, targetAgent int, workers int) error {
+	a.AddTurnLog(fmt.Sprintf("Attempting to raid Agent %d with %d workers", targetAgent, workers))
+
+	target, err := a.attackTarget(g, targetAgent)
+	if err != nil {
+		return fmt.Errorf("Failed to raid Agent %d: %w", targetAgent, err)
+	}
+
+	if a.Gold < g.Rules.RaidGoldCost {
+		return actionError(ErrInsufficientGold, "Failed to raid Agent %d, a raid costs %d gold", targetAgent, g.Rules.RaidGoldCost)
+	}
+
+	if workers > a.Workers-a.getOccupiedWorkers() {
+		return actionError(ErrInsufficientWorkers, "Failed to raid Agent %d, not enough unoccupied workers", targetAgent)
+	}
+
+	a.Gold -= g.Rules.RaidGoldCost
+
+	chance := g.Rules.RaidBaseSuccess + float64(workers-1)*g.Rules.RaidWorkerBonus - target.defence(g)
+	chance = math.Max(0, math.Min(chance, 1))
+
+	if g.rng.Float64() >= chance {
+		lost := (workers + 1) / 2
+		a.Workers -= lost
+
+		msg := fmt.Sprintf("Agent %d raided Agent %d with %d workers and was repelled, losing %d workers", a.ID, target.ID, workers, lost)
+		a.AddTurnLog(msg)
+		target.notify(msg)
+		g.recordEvent(RaidEvent, a.ID, target.ID, false, msg)
+
+		return actionError(ErrAttackFailed, "The raid was repelled")
+	}
+
+	stolenGold := int(float64(target.Gold) * g.Rules.RaidStealFraction)
+	stolenWheat := int(float64(target.Wheat) * g.Rules.RaidStealFraction)
+	target.Gold -= stolenGold
+	target.Wheat -= stolenWheat
+	a.Gold += stolenGold
+	a.Wheat += stolenWheat
+
+	msg := fmt.Sprintf("Agent %d raided Agent %d with %d workers and stole %d gold and %d wheat", a.ID, target.ID, workers, stolenGold, stolenWheat)
+	a.AddTurnLog(msg)
+	target.notify(msg)
+	g.recordEvent(RaidEvent, a.ID, target.ID, true, msg)
+
+	return nil
+}
+
+// SabotageBuilding pays gold to try and disable one of the target's buildings for a number of turns
+func (a *Agent) SabotageBuilding(g *Game, targetAgent int, buildingType string) error {
+	a.AddTurnLog(fmt.Sprintf("Attempting to sabotage a %s belonging to Agent %d", buildingType, targetAgent))
+
+	target, err := a.attackTarget(g, targetAgent)
+	if err != nil {
+		return fmt.Errorf("Failed to sabotage Agent %d: %w", targetAgent, err)
+	}
+
+	if a.Gold < g.Rules.SabotageCost {
+		return actionError(ErrInsufficientGold, "Failed to sabotage Agent %d, sabotage costs %d gold", targetAgent, g.Rules.SabotageCost)
+	}
+
+	building := -1
+	for i, b := range target.Buildings {
+		if b.Type == buildingType && b.DisabledTurns == 0 {
+			building = i
+			break
+		}
+	}
+
+	if building == -1 {
+		return actionError(ErrNoSuchBuilding, "Failed to sabotage Agent %d, they have no working %s", targetAgent, buildingType)
+	}
+
+	a.Gold -= g.Rules.SabotageCost
+
+	chance := math.Max(0, g.Rules.SabotageBaseSuccess-target.defence(g))
+	if g.rng.Float64() >= chance {
+		msg := fmt.Sprintf("Agent %d tried to sabotage a %s belonging to Agent %d but was caught", a.ID, buildingType, target.ID)
+		a.AddTurnLog(msg)
+		target.notify(msg)
+		g.recordEvent(SabotageEvent, a.ID, target.ID, false, msg)
+
+		return actionError(ErrAttackFailed, "The sabotage attempt was caught")
+	}
+
+	target.Buildings[building].DisabledTurns = g.Rules.SabotageDuration
+
+	msg := fmt.Sprintf("Agent %d sabotaged a %s belonging to Agent %d, it is disabled for %d turns", a.ID, buildingType, target.ID, g.Rules.SabotageDuration)
+	a.AddTurnLog(msg)
+	target.notify(msg)
+	g.recordEvent(SabotageEvent, a.ID, target.ID, true, msg)
+
+	return nil
+}
+
+// BuildWall buys a wall, permanently reducing the odds of raids and sabotage against the agent
+func (a *Agent) BuildWall(g *Game) error {
+	a.AddTurnLog("Attempting to build a wall")
+
+	if a.Gold < g.Rules.WallCost {
+		return actionError(ErrInsufficientGold, "Failed to build a wall, not enough gold")
+	}
+
+	a.Gold -= g.Rules.WallCost
+	a.Walls++
+	a.AddTurnLog(fmt.Sprintf("Built a wall for %d gold, you now have %d walls", g.Rules.WallCost, a.Walls))
+
+	return nil
+}
+
+// HireGuards adds guards to the agent if they can afford it. Guards eat wheat like idle workers.
+func (a *Agent) HireGuards(g *Game, count int) error {
+	a.AddTurnLog(fmt.Sprintf("Attempting to hire %d guards", count))
+
+	if !canAfford(a.Gold, count, g.Rules.GuardCost) {
+		return actionError(ErrInsufficientGold, "Failed to hire %d guards, not enough gold", count)
+	}
+
+	cost := count * g.Rules.GuardCost
+
+	a.Gold -= cost
+	a.Guards += count
+	a.AddTurnLog(fmt.Sprintf("Hired %d guards for %d gold", count, cost))
+
+	return nil
+}
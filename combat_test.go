@@ -0,0 +1,144 @@
+package main
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestDefence(t *testing.T) {
+	game := newTestGame(t, nil)
+
+	tests := []struct {
+		walls  int
+		guards int
+		want   float64
+	}{
+		{0, 0, 0},
+		{1, 0, WallDefence},
+		{2, 2, 2*WallDefence + 2*GuardDefence},
+		// Defence stops at MaxDefence however many walls and guards are added
+		{10, 10, MaxDefence},
+	}
+
+	for _, tt := range tests {
+		agent := Agent{Walls: tt.walls, Guards: tt.guards}
+		if got := agent.defence(game); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("%d walls and %d guards: defence %v, want %v", tt.walls, tt.guards, got, tt.want)
+		}
+	}
+}
+
+// TestRaidOdds raids repeatedly against a defended target, checking each outcome against the same
+// seeded random numbers the game draws
+func TestRaidOdds(t *testing.T) {
+	const seed = 42
+
+	game := newTestGame(t, func(r *Ruleset) {
+		r.NumAgents = 2
+		r.Seed = seed
+	})
+
+	attacker, target := &game.Agents[0], &game.Agents[1]
+	target.Walls = 1
+	target.Guards = 2
+
+	rng := rand.New(rand.NewSource(seed))
+	chance := RaidBaseSuccess + 2*RaidWorkerBonus - (WallDefence + 2*GuardDefence)
+
+	wins := 0
+	for i := 0; i < 200; i++ {
+		attacker.Gold, attacker.Workers = 1000, 3
+		target.Gold, target.Wheat = 100, 100
+
+		err := attacker.Raid(game, target.ID, 3)
+		if code := errorCode(err); code != "" && code != ErrAttackFailed {
+			t.Fatalf("raid %d could not be made: %v", i, err)
+		}
+
+		event := game.Events[len(game.Events)-1]
+		if want := rng.Float64() < chance; event.Success != want || (err == nil) != want {
+			t.Fatalf("raid %d: success %t, want %t", i, event.Success, want)
+		}
+
+		if event.Success {
+			wins++
+			if target.Gold != 75 || target.Wheat != 75 {
+				t.Fatalf("raid %d: target left with %d gold and %d wheat, want 75 of each", i, target.Gold, target.Wheat)
+			}
+		} else if attacker.Workers != 1 {
+			t.Fatalf("raid %d: repelled attacker has %d workers, want 1", i, attacker.Workers)
+		}
+	}
+
+	if wins == 0 || wins == 200 {
+		t.Errorf("%d of 200 raids succeeded at odds of %.2f", wins, chance)
+	}
+}
+
+func TestAttacksAgainstMaxDefence(t *testing.T) {
+	game := newTestGame(t, func(r *Ruleset) {
+		r.NumAgents = 2
+		r.Seed = 1
+		r.RaidBaseSuccess = 0.6
+		r.SabotageBaseSuccess = 0.6
+	})
+
+	attacker, target := &game.Agents[0], &game.Agents[1]
+	target.Walls = 100
+	target.Buildings = []Building{{Type: Farm}}
+
+	// The target's defence is clamped at 0.6, which leaves no chance for a lone raider or saboteur
+	for i := 0; i < 50; i++ {
+		attacker.Gold, attacker.Workers = 1000, 1
+		if err := attacker.Raid(game, target.ID, 1); errorCode(err) != ErrAttackFailed {
+			t.Fatalf("raid against maximum defence returned %v, want it repelled", err)
+		}
+		if err := attacker.SabotageBuilding(game, target.ID, Farm); errorCode(err) != ErrAttackFailed {
+			t.Fatalf("sabotage against maximum defence returned %v, want it caught", err)
+		}
+	}
+
+	if len(game.Events) != 100 {
+		t.Fatalf("recorded %d events, want 100", len(game.Events))
+	}
+
+	for _, event := range game.Events {
+		if event.Success {
+			t.Fatalf("%s succeeded against maximum defence: %s", event.Kind, event.Message)
+		}
+	}
+
+	// Without defences, the same attacks succeed at their base odds
+	target.Walls = 0
+	wins := 0
+	for i := 0; i < 200; i++ {
+		attacker.Gold, attacker.Workers = 1000, 1
+		target.Buildings[0].DisabledTurns = 0
+		if err := attacker.SabotageBuilding(game, target.ID, Farm); err == nil {
+			wins++
+			if target.Buildings[0].DisabledTurns != SabotageDuration {
+				t.Fatalf("sabotaged farm is disabled for %d turns, want %d", target.Buildings[0].DisabledTurns, SabotageDuration)
+			}
+		}
+	}
+
+	if wins < 80 || wins > 160 {
+		t.Errorf("%d of 200 sabotage attempts succeeded at odds of 0.6", wins)
+	}
+}
+
+func TestAttackTarget(t *testing.T) {
+	game := newTestGame(t, func(r *Ruleset) { r.NumAgents = 3 })
+	game.Agents[2].Lost = true
+
+	for target, code := range map[int]string{-1: ErrInvalidTarget, 0: ErrInvalidTarget, 2: ErrTargetEliminated, 3: ErrInvalidTarget} {
+		if _, err := game.Agents[0].attackTarget(game, target); errorCode(err) != code {
+			t.Errorf("attacking Agent %d returned %v, want %s", target, err, code)
+		}
+	}
+
+	if _, err := game.Agents[0].attackTarget(game, 1); err != nil {
+		t.Errorf("Agent 0 could not attack Agent 1: %v", err)
+	}
+}
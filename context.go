@@ -0,0 +1,239 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+// Summary modes
+const (
+	// LLMSummary asks the agent's model to summarise their older turns
+	LLMSummary = "llm"
+	// CompressedSummary condenses older turns from the game log without calling the model
+	CompressedSummary = "compressed"
+)
+
+// defaultContextLimit is assumed for models missing from contextLimits
+const defaultContextLimit = 8192
+
+// contextLimits is the context window of known models in tokens, matched by prefix, most
+// specific first
+var contextLimits = []struct {
+	prefix string
+	tokens int
+}{
+	{"gpt-4o", 128000},
+	{"gpt-4-turbo", 128000},
+	{"gpt-4-1106", 128000},
+	{"gpt-4-0125", 128000},
+	{"gpt-4-32k", 32768},
+	{"gpt-4", 8192},
+	{"gpt-3.5-turbo-instruct", 4096},
+	{"gpt-3.5-turbo", 16385},
+}
+
+// turnMark records where one of the agent's turns begins in their prompt
+type turnMark struct {
+	turn  int
+	index int
+}
+
+// contextLimit returns the size of the model's context window in tokens
+func contextLimit(model string) int {
+	for _, limit := range contextLimits {
+		if strings.HasPrefix(model, limit.prefix) {
+			return limit.tokens
+		}
+	}
+
+	return defaultContextLimit
+}
+
+// tokenBudget is how large the prompt may grow before older turns are summarised early. A quarter
+// of the window is left for the tool definitions and the response.
+func (r Ruleset) tokenBudget() int {
+	if r.ContextTokenLimit > 0 {
+		return r.ContextTokenLimit
+	}
+
+	return contextLimit(r.Model) * 3 / 4
+}
+
+// estimateTokens approximates the size of messages in tokens, at about four characters a token
+// plus the few tokens each message adds
+func estimateTokens(messages []openai.ChatCompletionMessage) int {
+	chars := 0
+	for _, message := range messages {
+		chars += len(message.Content)
+		for _, toolCall := range message.ToolCalls {
+			chars += len(toolCall.Function.Name) + len(toolCall.Function.Arguments)
+		}
+	}
+
+	return chars/4 + 4*len(messages) + 3
+}
+
+// manageContext keeps the agent's prompt within the context window. The system prompt, the current
+// turn and the last ContextWindowTurns turns are kept verbatim, and older turns are folded into a
+// rolling summary every SummaryEveryTurns turns, or straight away if the prompt has grown too large.
+func (a *Agent) manageContext(g *Game) {
+	window := g.Rules.ContextWindowTurns
+	if window <= 0 {
+		return
+	}
+
+	overBudget := estimateTokens(a.Prompt) > g.Rules.tokenBudget()
+	if overBudget {
+		window = 0
+	}
+
+	// The last mark is the current turn, which is always kept
+	older := len(a.history) - 1 - window
+	if older <= 0 {
+		return
+	}
+
+	if !overBudget && older < max(g.Rules.SummaryEveryTurns, 1) {
+		return
+	}
+
+	a.summariseTurns(g, older)
+}
+
+// summariseTurns replaces the agent's oldest count turns in their prompt with a summary
+func (a *Agent) summariseTurns(g *Game, count int) {
+	head := a.headerLen()
+	cut := a.history[count].index
+	from, to := a.history[0].turn, a.history[count-1].turn
+
+	if g.Rules.SummaryMode == LLMSummary {
+		summary, err := a.llmSummary(g, a.Prompt[head:cut])
+		if err == nil {
+			a.summary = summary
+		} else {
+			fmt.Printf("Agent %d failed to summarise turns %d to %d, compressing them instead: %v\n", a.ID, from, to, err)
+			a.summary = a.compressedSummary(g, count)
+		}
+	} else {
+		a.summary = a.compressedSummary(g, count)
+	}
+	a.summarisedTo = to
+
+	prompt := make([]openai.ChatCompletionMessage, 0, head+len(a.Prompt)-cut)
+	prompt = append(prompt, a.Prompt[:head]...)
+	prompt = append(prompt, a.Prompt[cut:]...)
+
+	shift := cut - head
+	history := make([]turnMark, 0, len(a.history)-count)
+	for _, mark := range a.history[count:] {
+		history = append(history, turnMark{turn: mark.turn, index: mark.index - shift})
+	}
+
+	notices := []int{}
+	for _, index := range a.notices {
+		if index >= cut {
+			notices = append(notices, index-shift)
+		}
+	}
+
+	fmt.Printf("Agent %d: summarised turns %d to %d, prompt shrank from %d to %d messages\n", a.ID, from, to, len(a.Prompt), len(prompt))
+
+	a.Prompt = prompt
+	a.history = history
+	a.notices = notices
+	a.updateMemory()
+}
+
+// llmSummary asks the agent's model to fold the given messages into their running summary
+func (a *Agent) llmSummary(g *Game, messages []openai.ChatCompletionMessage) (string, error) {
+	transcript := ""
+	if a.summary != "" {
+		transcript += fmt.Sprintf("Summary of earlier turns:\n%s\n\n", a.summary)
+	}
+
+	transcript += "Record of the turns since:\n"
+	for _, message := range messages {
+		if message.Content != "" {
+			transcript += fmt.Sprintf("[%s] %s\n", message.Role, strings.TrimSpace(message.Content))
+		}
+
+		for _, toolCall := range message.ToolCalls {
+			transcript += fmt.Sprintf("[%s] called %s(%s)\n", message.Role, toolCall.Function.Name, toolCall.Function.Arguments)
+		}
+	}
+
+	request := g.chatRequest([]openai.ChatCompletionMessage{
+		{
+			Role: "system",
+			Content: fmt.Sprintf("You are Agent %d in a multiplayer economic strategy game. Your older turns are about to be removed from your memory. "+
+				"Write a concise summary of them for your own future reference, keeping every fact you may need later: how your resources changed, "+
+				"deals, promises and messages between you and other agents and whether they were kept, attacks, alliances, votes, and your plans.", a.ID),
+		},
+		{Role: "user", Content: transcript},
+	})
+
+	return getReasoningFromLM(g.ctx, a.llm, request)
+}
+
+// compressedSummary condenses the agent's oldest count turns into a line each, from their turn
+// records and the notices they received, and adds them to their running summary
+func (a *Agent) compressedSummary(g *Game, count int) string {
+	lines := []string{}
+	if a.summary != "" {
+		lines = append(lines, a.summary)
+	}
+
+	for i := 0; i < count; i++ {
+		mark := a.history[i]
+
+		line := fmt.Sprintf("Turn %d:", mark.turn)
+		for _, turn := range g.GameLog {
+			if turn.AgentID != a.ID || turn.Turn != mark.turn {
+				continue
+			}
+
+			line += fmt.Sprintf(" Gold %d -> %d, Wheat %d -> %d, Workers %d -> %d, Buildings %d -> %d.",
+				turn.StartState.Gold, turn.EndState.Gold, turn.StartState.Wheat, turn.EndState.Wheat,
+				turn.StartState.Workers, turn.EndState.Workers, len(turn.StartState.Buildings), len(turn.EndState.Buildings))
+
+			actions := []string{}
+			for _, action := range turn.Actions {
+				actions = append(actions, compressAction(action))
+			}
+			if len(actions) > 0 {
+				line += " Actions: " + strings.Join(actions, ", ") + "."
+			}
+
+			for _, event := range turn.Events {
+				line += " " + event.Message + "."
+			}
+		}
+
+		for _, index := range a.notices {
+			if index >= mark.index && index < a.history[i+1].index {
+				line += " " + strings.TrimSpace(a.Prompt[index].Content)
+			}
+		}
+
+		lines = append(lines, line)
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+func compressAction(action ActionResult) string {
+	var args bytes.Buffer
+	if err := json.Compact(&args, []byte(action.Arguments)); err != nil || args.String() == "{}" {
+		args.Reset()
+	}
+
+	if action.Success {
+		return fmt.Sprintf("%s%s succeeded", action.Action, args.String())
+	}
+
+	return fmt.Sprintf("%s%s failed (%s)", action.Action, args.String(), action.ErrorCode)
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func withCompressedSummaries(r *Ruleset) {
+	r.NumAgents = 3
+	r.SummaryMode = CompressedSummary
+}
+
+// TestCompressedSummaryKeepsNotices checks that what other agents did to an agent survives their
+// turn being compressed, while the agent's own working is dropped
+func TestCompressedSummaryKeepsNotices(t *testing.T) {
+	game := newTestGame(t, withCompressedSummaries)
+	agent, raider, ally := &game.Agents[0], &game.Agents[1], &game.Agents[2]
+
+	agent.StartTurn(game)
+	if err := agent.BuildWall(game); err != nil {
+		t.Fatal(err)
+	}
+
+	raider.Workers = 3
+	if err := raider.Raid(game, agent.ID, 1); errorCode(err) != "" && errorCode(err) != ErrAttackFailed {
+		t.Fatal(err)
+	}
+	raid := game.Events[len(game.Events)-1].Message
+
+	if err := ally.GiveResource(game, agent.ID, Resource{Type: Gold, Amount: 5}); err != nil {
+		t.Fatal(err)
+	}
+	if err := ally.ProposeAlliance(game, agent.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := ally.MakePromise(game, agent.ID, Resource{Type: Wheat, Amount: 10}, 2); err != nil {
+		t.Fatal(err)
+	}
+
+	agent.StartTurn(game)
+	agent.summariseTurns(game, 1)
+
+	for _, want := range []string{
+		raid,
+		"You have received 5 gold from Agent 2",
+		"Agent 2 has proposed an alliance with you",
+		"Agent 2 has promised to give you 10 Wheat by the end of round 2",
+	} {
+		if !strings.Contains(agent.summary, want) {
+			t.Errorf("summary is missing %q:\n%s", want, agent.summary)
+		}
+	}
+
+	if strings.Contains(agent.summary, "Attempting") {
+		t.Errorf("summary kept the agent's own working:\n%s", agent.summary)
+	}
+}
+
+// TestSummariseTurnsShiftsIndexes checks that the turns and notices kept after a summary still
+// point at the same messages
+func TestSummariseTurnsShiftsIndexes(t *testing.T) {
+	game := newTestGame(t, withCompressedSummaries)
+	agent, other := &game.Agents[0], &game.Agents[1]
+
+	for turn := 1; turn <= 3; turn++ {
+		agent.StartTurn(game)
+		if err := other.GiveResource(game, agent.ID, Resource{Type: Gold, Amount: turn}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	turnStarts := map[int]string{}
+	for _, mark := range agent.history {
+		turnStarts[mark.turn] = agent.Prompt[mark.index].Content
+	}
+	notices := []string{}
+	for _, index := range agent.notices {
+		notices = append(notices, agent.Prompt[index].Content)
+	}
+
+	agent.summariseTurns(game, 2)
+
+	if len(agent.history) != 1 || agent.history[0].turn != 3 || agent.history[0].index != 2 {
+		t.Fatalf("history after summarising two turns: %+v", agent.history)
+	}
+	if got := agent.Prompt[2].Content; got != turnStarts[3] {
+		t.Errorf("turn 3 starts at %q, want %q", got, turnStarts[3])
+	}
+
+	if len(agent.notices) != 1 {
+		t.Fatalf("%d notices kept, want 1", len(agent.notices))
+	}
+	if got := agent.Prompt[agent.notices[0]].Content; got != notices[2] {
+		t.Errorf("notice points at %q, want %q", got, notices[2])
+	}
+
+	// A second summary starts after the first one, which is now part of the prompt
+	agent.StartTurn(game)
+	agent.summariseTurns(game, 1)
+
+	if len(agent.history) != 1 || agent.history[0].turn != 4 || agent.history[0].index != 2 {
+		t.Fatalf("history after summarising again: %+v", agent.history)
+	}
+	if len(agent.notices) != 0 {
+		t.Errorf("%d notices kept after their turn was summarised", len(agent.notices))
+	}
+	if !strings.Contains(agent.summary, "You have received 1 gold") || !strings.Contains(agent.summary, "You have received 3 gold") {
+		t.Errorf("rolling summary lost earlier notices:\n%s", agent.summary)
+	}
+}
@@ -1,7 +1,11 @@
 package main
 
 import (
+	"context"
 	"fmt"
+	"math/rand"
+	"sync"
+	"time"
 
 	"github.com/gorilla/websocket"
 	openai "github.com/sashabaranov/go-openai"
@@ -40,8 +44,42 @@ const (
 
 	ActionsPerTurn = 1
 
+	InvalidActionRetries = 2
+
+	DefaultModel       = openai.GPT3Dot5Turbo
+	CallTimeoutSeconds = 60
+	MaxRetries         = 3
+	RetryDelayMillis   = 1000
+	MaxForfeits        = 3
+
+	ContextWindowTurns = 5
+	SummaryEveryTurns  = 5
+
+	FreeCallsPerTurn = 5
+	NotebookSize     = 20
+
+	NegotiationMessages = 2
+
+	InformationDelay = 2
+	InformationNoise = 0.2
+
 	WinningGoldAmount = 1000
 	MaxTurns          = 100
+
+	RaidGoldCost      = 10
+	RaidBaseSuccess   = 0.5
+	RaidWorkerBonus   = 0.1
+	RaidStealFraction = 0.25
+
+	SabotageCost        = 15
+	SabotageBaseSuccess = 0.5
+	SabotageDuration    = 3
+
+	WallCost     = 25
+	WallDefence  = 0.1
+	GuardCost    = 10
+	GuardDefence = 0.05
+	MaxDefence   = 0.6
 )
 
 // [0]                 <- turn 0
@@ -49,7 +87,7 @@ const (
 //       [AgentID]
 //       [StartState]
 //       [Strategy]
-//       [Action]
+//       [Actions]
 //       [EndState]
 //       [FullPrompt]
 //    [1] ...
@@ -64,104 +102,214 @@ type Resource struct {
 
 // Building represents a production building
 type Building struct {
-	Type   string
-	Manned bool
+	Type          string
+	Manned        bool
+	DisabledTurns int
 }
 
 // Game represents the overall game state
 type Game struct {
-	Agents       []Agent
-	GameLog      GameLog
-	CurrentTurn  int
-	Winner       *Agent
-	Websocket    *websocket.Conn
-	Done         chan struct{}
-	OpenAIapiKey string
+	Agents      []Agent
+	GameLog     GameLog
+	CurrentTurn int
+	Winner      *Agent
+	Websocket   *websocket.Conn
+	Done        chan struct{}
+	endOnce     sync.Once
+	// ctx is cancelled when the game ends, abandoning any calls to the model in flight
+	ctx    context.Context
+	cancel context.CancelFunc
+	Rules  Ruleset
+	Events []GameEvent
+
+	// Seed is the source of all of the engine's randomness. Together with the
+	// transcripts of every model call it is enough to replay a game exactly.
+	Seed        int64
+	Transcripts [][]LLMExchange
+	rng         *rand.Rand
+	startRules  Ruleset
+
+	Alliances         []Alliance
+	AllianceProposals []AllianceProposal
+	WinningAlliance   *Alliance
+	nextAllianceID    int
+
+	Treasury     Treasury
+	Policies     []Policy
+	nextPolicyID int
+
+	PriceHistory []Prices
+
+	// Channels holds every chat channel and its history, starting with the town square
+	Channels []Channel
+	// unpushedMessages are chat messages not yet sent to the client
+	unpushedMessages []ChatMessage
+
+	// Promises tracks every promise made with make_promise
+	Promises []Promise
+	// unpushedPromises are promises made, kept or broken that the client has not yet been sent
+	unpushedPromises []Promise
+
+	// OverBudget is set when the game was ended for costing more than MaxCost
+	OverBudget bool
+	// holdings snapshots every agent's holdings at the start of each round
+	holdings [][]State
+
+	tools   []openai.Tool
+	prompts *PromptTemplates
+
+	// planning is held by an agent planning in simultaneous mode while they use a free tool, as
+	// those tools read other agents' state and the game's history
+	planning sync.Mutex
 }
 
 type GameLog []AgentTurn
 
+// GameEvent records something that happened between agents, such as a raid
+type GameEvent struct {
+	Turn     int
+	Kind     string
+	AgentID  int
+	TargetID int
+	Success  bool
+	Message  string
+}
+
+// ForfeitEvent is the event kind recorded when an agent forfeits their turn
+const ForfeitEvent = "forfeit"
+
 type AgentTurn struct {
 	Turn                int
 	AgentID             int
 	StartState          State
 	Strategy            string
-	Action              string
+	Actions             []ActionResult
 	EndState            State
 	PostRationalisation string
 	FullPrompt          []openai.ChatCompletionMessage
+	Events              []GameEvent
 	Error               error
+	// Forfeited is set when the turn was given up because the model could not be reached
+	Forfeited bool
+	// ContextTokens estimates the size of the agent's prompt at the end of the turn
+	ContextTokens int
+	// Notes is the agent's private notebook at the end of the turn
+	Notes []Note
+	// PromptVersion identifies the prompt templates the agent was playing with
+	PromptVersion string
+	// StructuredStrategy and StructuredRationalisation are the agent's plan and beliefs at the
+	// start and end of the turn, when the ruleset asks for structured strategies
+	StructuredStrategy        *StructuredStrategy
+	StructuredRationalisation *StructuredStrategy
+	// Usage is the agent's use of the model during the turn, and AgentUsage and GameUsage their
+	// own and the whole game's use so far
+	Usage      Usage
+	AgentUsage Usage
+	GameUsage  Usage
+	// ChatMessages are the messages posted on any channel since the previous turn was pushed
+	ChatMessages []ChatMessage
+	// Negotiation holds the messages the agent sent in the negotiation phase before the turn, and
+	// NegotiationState their holdings when it began, before the turn's feeding and production
+	Negotiation      []ActionResult
+	NegotiationState *State
+	// Promises are the promises made, kept or broken since the previous turn was pushed, and
+	// Reliability every agent's record of keeping them so far
+	Promises    []Promise
+	Reliability []Reliability
 }
 
 type State struct {
 	Gold      int
 	Wheat     int
 	Workers   int
+	Walls     int
+	Guards    int
 	Buildings []Building
 }
 
-// NewGame initializes a new game with the specified number of agents
-func NewGame(conn *websocket.Conn, openAIapiKey string) *Game {
+// NewGame initializes a new game with the number of agents set by the ruleset
+func NewGame(conn *websocket.Conn, client ChatClient, rules Ruleset) (*Game, error) {
+	seed := rules.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+
+	prompts, err := LoadPromptTemplates(rules)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
 	game := &Game{
-		Agents:       make([]Agent, NumAgents),
-		GameLog:      GameLog{},
-		CurrentTurn:  0,
-		Winner:       nil,
-		Websocket:    conn,
-		Done:         make(chan struct{}),
-		OpenAIapiKey: openAIapiKey,
+		Agents:      make([]Agent, rules.NumAgents),
+		GameLog:     GameLog{},
+		CurrentTurn: 0,
+		Winner:      nil,
+		Websocket:   conn,
+		Done:        make(chan struct{}),
+		ctx:         ctx,
+		cancel:      cancel,
+		Rules:       rules,
+		Seed:        seed,
+		Transcripts: make([][]LLMExchange, rules.NumAgents),
+		rng:         rand.New(rand.NewSource(seed)),
+		startRules:  rules,
+		Channels:    []Channel{newTownSquare(rules.NumAgents)},
+		tools:       getToolDefinitions(rules),
+		prompts:     prompts,
 	}
 
-	for i := 0; i < NumAgents; i++ {
+	// Every agent's calls get the same deadlines, retries and fallback
+	client = NewRetryingClient(client, rules)
+
+	for i := 0; i < rules.NumAgents; i++ {
+		profile := rules.profileFor(i)
+		agentRules := profile.Apply(rules)
+
+		prompt, err := game.prompts.basePrompt(agentRules, i, profile, len(rules.Profiles) > 0)
+		if err != nil {
+			cancel()
+			return nil, err
+		}
+
 		game.Agents[i] = Agent{
 			ID:        i,
-			Gold:      StartingGold,
-			Wheat:     StartingWheat,
-			Workers:   StartingWorkers,
+			Gold:      agentRules.StartingGold,
+			Wheat:     agentRules.StartingWheat,
+			Workers:   agentRules.StartingWorkers,
 			Buildings: []Building{},
-			Prompt:    basePrompt(),
+			Profile:   profile,
+			Prompt:    prompt,
 			Lost:      false,
+			llm:       &recordingClient{client: client, transcript: &game.Transcripts[i], usage: &game.Agents[i].Usage},
+
+			promptVersion: game.prompts.Version(profile.Persona),
 		}
 	}
 
-	return game
+	return game, nil
 }
 
 // RunGame manages the main game loop
 func RunGame(game *Game) {
-	for game.CurrentTurn < MaxTurns && game.Winner == nil {
-
-		for i := range game.Agents {
-			var agentTurn AgentTurn
-			select {
-			case <-game.Done:
-				fmt.Printf("Game end detected, breaking out of game loop\n")
-				break
-			default:
-			}
-
-			if game.Agents[i].Lost {
-				continue
-			}
-
-			agentTurn = ProcessTurn(&game.Agents[i], game)
-
-			if game.Agents[i].Gold >= WinningGoldAmount {
-				game.Winner = &game.Agents[i]
-				break
-			}
-
-			if isLastAgent(game.Agents, game.Agents[i].ID) {
-				game.Winner = &game.Agents[i]
-			}
-
-			err := game.PushGameState(agentTurn)
-			if err != nil {
-				fmt.Printf("Failed to push game state: %v\n", err)
-				break
-			}
+	for game.CurrentTurn < game.Rules.MaxTurns && game.Winner == nil && game.WinningAlliance == nil && !game.ended() {
+
+		order := game.turnOrder()
+		game.announceTurnOrder(order)
+		game.recordPrices()
+		game.recordHoldings()
+		game.negotiate(order)
+
+		if game.Rules.TurnMode == SimultaneousTurns {
+			RunSimultaneousRound(game, order)
+		} else {
+			runSequentialRound(game, order)
 		}
 
+		game.ResolvePolicies()
+		game.checkPromises()
+
 		game.CurrentTurn++
 
 	}
@@ -169,21 +317,48 @@ func RunGame(game *Game) {
 	fmt.Printf("Game loop exiting after %d turns\n", game.CurrentTurn)
 }
 
+// runSequentialRound gives each agent their turn in order, resolving each action as it is taken
+func runSequentialRound(game *Game, order []int) {
+	for _, i := range order {
+		if game.ended() {
+			fmt.Printf("Game end detected, breaking out of game loop\n")
+			return
+		}
+
+		if game.Agents[i].Lost {
+			continue
+		}
+
+		agentTurn := ProcessTurn(&game.Agents[i], game)
+
+		if game.recordTurn(&game.Agents[i], agentTurn) {
+			return
+		}
+	}
+}
+
 // ProcessTurn handles a single agent's turn
 func ProcessTurn(agent *Agent, game *Game) AgentTurn {
-	agent.IncrementTurn() // Increment the agent's turn counter
-	agent.FeedWorkers()
-	agent.ProduceResources()
-	agent.DecayWheat()
+	if err := agent.StartTurn(game); err != nil {
+		fmt.Printf("Agent %d's turn could not start, ending the game: %v\n", agent.ID, err)
+		game.End()
+
+		agentTurn := agent.newTurn()
+		agentTurn.Error = err
+		return agentTurn
+	}
 
-	agent.AddTurnLog(getTurnPrompt())
+	eventCount := len(game.Events)
 
-	agentTurn, err := agent.TakeTurn(game, ActionsPerTurn)
+	agentTurn, err := agent.TakeTurn(game, game.rulesFor(agent.ID).ActionsPerTurn)
 	if err != nil {
-		fmt.Printf("Agent %d failed to take turn: %v\n", agent.ID, err)
-		game.End()
+		game.forfeitTurn(agent, agentTurn, err)
+	} else {
+		agent.forfeits = 0
 	}
 
+	agentTurn.Events = game.Events[eventCount:]
+
 	agent.EndTurn(game)
 
 	fmt.Printf("Agent %d's turn ended\n", agent.ID)
@@ -191,28 +366,174 @@ func ProcessTurn(agent *Agent, game *Game) AgentTurn {
 	return *agentTurn
 }
 
+// forfeitTurn gives up the rest of an agent's turn when the model could not be reached, so one
+// failed call doesn't end the game. The game ends once an agent forfeits MaxForfeits turns in a row.
+func (g *Game) forfeitTurn(agent *Agent, agentTurn *AgentTurn, err error) {
+	fmt.Printf("Agent %d forfeited their turn: %v\n", agent.ID, err)
+
+	agentTurn.Error = err
+	agentTurn.Forfeited = true
+	agentTurn.FullPrompt = agent.Prompt
+	agentTurn.ContextTokens = estimateTokens(agent.Prompt)
+	agentTurn.Notes = agent.Notes
+	agentTurn.EndState = agent.state()
+
+	// Calls abandoned because the game was ended are not the agent's fault
+	if g.ended() {
+		return
+	}
+
+	agent.forfeits++
+
+	msg := fmt.Sprintf("Agent %d forfeited the rest of their turn because their model could not be reached", agent.ID)
+	agent.AddTurnLog("You forfeited the rest of your turn because your model could not be reached")
+	g.recordEvent(ForfeitEvent, agent.ID, -1, false, msg)
+
+	if g.Rules.MaxForfeits > 0 && agent.forfeits >= g.Rules.MaxForfeits {
+		fmt.Printf("Agent %d has forfeited %d turns in a row, ending the game\n", agent.ID, agent.forfeits)
+		g.End()
+	}
+}
+
+// recordTurn checks whether the agent's turn has decided the game and pushes it to the client.
+// It returns true if the round should stop.
+func (g *Game) recordTurn(agent *Agent, agentTurn AgentTurn) bool {
+	if agent.Gold >= g.Rules.WinningGoldAmount {
+		g.Winner = agent
+		return true
+	}
+
+	if isLastAgent(g.Agents, agent.ID) {
+		g.Winner = agent
+	}
+
+	agentTurn.Usage = agent.Usage.since(agent.turnUsage)
+	agentTurn.AgentUsage = agent.Usage
+	agentTurn.GameUsage = g.usage()
+	agentTurn.ChatMessages = g.unpushedMessages
+	g.unpushedMessages = nil
+	agentTurn.Promises = g.unpushedPromises
+	g.unpushedPromises = nil
+	agentTurn.Reliability = g.reliability()
+
+	err := g.PushGameState(agentTurn)
+	if err != nil {
+		fmt.Printf("Failed to push game state: %v\n", err)
+		return true
+	}
+
+	if alliance := g.winningAlliance(); alliance != nil {
+		g.WinningAlliance = alliance
+		return true
+	}
+
+	// Ending the game stops any more turns being played, while turns already played this round are
+	// still recorded
+	if !g.OverBudget && g.overBudget() {
+		fmt.Printf("The game has cost $%.4f, over its budget of $%.4f, ending the game\n", g.usage().Cost, g.Rules.MaxCost)
+		g.OverBudget = true
+		g.End()
+	}
+
+	return false
+}
+
+// Outcome is how a game ended
+type Outcome struct {
+	// Winners are the winning agent or the members of the winning alliance, and empty if nobody won
+	Winners []int
+	Reason  string
+	Rounds  int
+}
+
+// outcome describes who won the game and why
+func (g *Game) outcome() Outcome {
+	outcome := Outcome{Winners: []int{}, Rounds: g.CurrentTurn}
+
+	switch {
+	case g.Winner != nil && g.Winner.Gold >= g.Rules.WinningGoldAmount:
+		outcome.Winners = []int{g.Winner.ID}
+		outcome.Reason = fmt.Sprintf("Agent %d reached %d gold", g.Winner.ID, g.Rules.WinningGoldAmount)
+	case g.Winner != nil:
+		outcome.Winners = []int{g.Winner.ID}
+		outcome.Reason = fmt.Sprintf("Agent %d was the last agent standing", g.Winner.ID)
+	case g.WinningAlliance != nil:
+		outcome.Winners = g.WinningAlliance.Members
+		outcome.Reason = fmt.Sprintf("Alliance %d (Agents %s) held %d gold between them", g.WinningAlliance.ID, joinAgentIDs(g.WinningAlliance.Members), g.Rules.AllianceVictoryGold)
+	case g.OverBudget:
+		outcome.Reason = "No winner, the game went over its budget"
+	case g.CurrentTurn >= g.Rules.MaxTurns:
+		outcome.Reason = "No winner, the turn limit was reached"
+	default:
+		outcome.Reason = "No winner, the game was ended early"
+	}
+
+	return outcome
+}
+
 // PrintGameResult displays the final game state
 func PrintGameResult(game *Game) {
 	fmt.Printf("Game ended after %d turns\n", game.CurrentTurn)
-	if game.Winner != nil {
-		fmt.Printf("Winner: Agent %d\n", game.Winner.ID)
-	} else {
-		fmt.Println("No winner (max turns reached)")
-	}
+	fmt.Println(game.outcome().Reason)
 
 	for _, agent := range game.Agents {
-		fmt.Printf("Agent %d: Gold=%d, Wheat=%d, Workers=%d, Buildings=%d\n",
-			agent.ID, agent.Gold, agent.Wheat, agent.Workers, len(agent.Buildings))
+		fmt.Printf("Agent %d: Gold=%d, Wheat=%d, Workers=%d, Buildings=%d, Tokens=%d, Cost=$%.4f\n",
+			agent.ID, agent.Gold, agent.Wheat, agent.Workers, len(agent.Buildings),
+			agent.Usage.PromptTokens+agent.Usage.CompletionTokens, agent.Usage.Cost)
 	}
+
+	if len(game.Promises) > 0 {
+		for _, score := range game.reliability() {
+			fmt.Printf("Agent %d promises: %d kept, %d broken, %d pending\n", score.AgentID, score.Kept, score.Broken, score.Pending)
+		}
+
+		for _, promise := range game.brokenPromises() {
+			fmt.Printf("Broken promise: %s, but only gave %d\n", promise, promise.Delivered)
+		}
+	}
+
+	deception := AnalyseDeception(game.GameLog, game.startRules)
+	if len(deception.Lies) > 0 {
+		for _, score := range deception.Agents {
+			fmt.Printf("Agent %d honesty: %d messages, %d checkable claims, %d false\n", score.AgentID, score.Messages, score.Claims, score.Lies)
+		}
+
+		for _, lie := range deception.Lies {
+			fmt.Printf("Lie: Agent %d claimed %q on turn %d, but the truth is %d\n", lie.AgentID, lie.Claim, lie.Turn, lie.Actual)
+		}
+	}
+
+	usage := game.usage()
+	fmt.Printf("Model usage: %d calls, %d prompt tokens, %d completion tokens, $%.4f\n",
+		usage.Calls, usage.PromptTokens, usage.CompletionTokens, usage.Cost)
 }
 
 func (g *Game) End() {
-	fmt.Printf("Ending game after %d turns\n", g.CurrentTurn)
-	close(g.Done)
+	g.endOnce.Do(func() {
+		fmt.Printf("Ending game after %d turns\n", g.CurrentTurn)
+		close(g.Done)
+		g.cancel()
+	})
+}
+
+// ended reports whether the game has been ended
+func (g *Game) ended() bool {
+	select {
+	case <-g.Done:
+		return true
+	default:
+		return false
+	}
 }
 
 func (g *Game) PushGameState(agentTurn AgentTurn) error {
 	g.GameLog = append(g.GameLog, agentTurn)
+
+	// Headless games, such as replays run offline, have no client to push to
+	if g.Websocket == nil {
+		return nil
+	}
+
 	if err := g.Websocket.WriteJSON(agentTurn); err != nil {
 		return fmt.Errorf("failed to write game state to websocket: %w", err)
 	}
@@ -220,23 +541,84 @@ func (g *Game) PushGameState(agentTurn AgentTurn) error {
 	return nil
 }
 
-func (g *Game) broadcastMessage(message string, fromAgentID int) {
-	for i := range g.Agents {
-		if i != fromAgentID {
-			g.Agents[i].AddTurnLog(message)
-		}
+// currentSeason returns the active season, if seasons are enabled
+func (g *Game) currentSeason() (Season, bool) {
+	if !g.Rules.SeasonsEnabled() {
+		return Season{}, false
 	}
+
+	season, _ := g.Rules.SeasonAt(g.CurrentTurn)
+
+	return season, true
 }
 
-func (g *Game) sendMessage(targetAgentID int, message string) error {
-	recipient := g.Agents[targetAgentID]
-	if recipient.Lost {
-		return fmt.Errorf("cannot send message to lost agent")
+// farmProduction is the wheat a manned farm owned by the agent produces this turn
+func (g *Game) farmProduction(agentID int) int {
+	production := g.rulesFor(agentID).FarmProduction
+
+	season, ok := g.currentSeason()
+	if !ok {
+		return production
 	}
 
-	g.Agents[targetAgentID].AddTurnLog(message)
+	return int(float64(production) * season.FarmMultiplier)
+}
 
-	return nil
+// mineProduction is the gold a manned mine owned by the agent produces this turn
+func (g *Game) mineProduction(agentID int) int {
+	production := g.rulesFor(agentID).MineProduction
+
+	season, ok := g.currentSeason()
+	if !ok {
+		return production
+	}
+
+	return int(float64(production) * season.MineMultiplier)
+}
+
+// wheatDecayRate is the fraction of wheat that spoils this turn
+func (g *Game) wheatDecayRate() float64 {
+	season, ok := g.currentSeason()
+	if !ok {
+		return g.Rules.WheatDecayRate
+	}
+
+	return min(1, g.Rules.WheatDecayRate*season.WheatDecayMultiplier)
+}
+
+// seasonState describes the current season for an agent's game state
+func (g *Game) seasonState() string {
+	if !g.Rules.SeasonsEnabled() {
+		return ""
+	}
+
+	season, remaining := g.Rules.SeasonAt(g.CurrentTurn)
+	next, _ := g.Rules.SeasonAt(g.CurrentTurn + remaining)
+
+	return fmt.Sprintf(
+		"Season: %s (farms x%.2f, mines x%.2f, wheat decay %.2f), %d turn(s) remaining, next season: %s\n",
+		season.Name, season.FarmMultiplier, season.MineMultiplier, g.wheatDecayRate(), remaining, next.Name,
+	)
+}
+
+// recordEvent adds an event to the game log
+func (g *Game) recordEvent(kind string, agentID int, targetID int, success bool, message string) {
+	g.Events = append(g.Events, GameEvent{
+		Turn:     g.CurrentTurn,
+		Kind:     kind,
+		AgentID:  agentID,
+		TargetID: targetID,
+		Success:  success,
+		Message:  message,
+	})
+}
+
+func (g *Game) broadcastMessage(message string, fromAgentID int) {
+	for i := range g.Agents {
+		if i != fromAgentID {
+			g.Agents[i].notify(message)
+		}
+	}
 }
 
 func isLastAgent(agents []Agent, agentID int) bool {
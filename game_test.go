@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+// stubClient answers every call to the model without a network. Calls that must use a tool get
+// the tool calls returned by actions, and every other call gets a plain message.
+type stubClient struct {
+	mu      sync.Mutex
+	calls   int
+	actions func(call int) []openai.FunctionCall
+}
+
+func (c *stubClient) CreateChatCompletion(_ context.Context, request openai.ChatCompletionRequest) (openai.ChatCompletionResponse, error) {
+	c.mu.Lock()
+	c.calls++
+	call := c.calls
+	c.mu.Unlock()
+
+	message := openai.ChatCompletionMessage{Role: openai.ChatMessageRoleAssistant, Content: "I have a plan."}
+	if len(request.Tools) > 0 && request.ToolChoice != "none" && c.actions != nil {
+		for i, function := range c.actions(call) {
+			message.ToolCalls = append(message.ToolCalls, openai.ToolCall{
+				ID:       fmt.Sprintf("call_%d_%d", call, i),
+				Type:     openai.ToolTypeFunction,
+				Function: function,
+			})
+		}
+	}
+
+	return openai.ChatCompletionResponse{
+		Model:   request.Model,
+		Choices: []openai.ChatCompletionChoice{{Message: message}},
+		Usage:   openai.Usage{PromptTokens: 100, CompletionTokens: 10},
+	}, nil
+}
+
+// newTestGame starts a game on the default ruleset, changed by configure if it is not nil
+func newTestGame(t *testing.T, configure func(*Ruleset)) *Game {
+	t.Helper()
+
+	rules := DefaultRuleset()
+	if configure != nil {
+		configure(&rules)
+	}
+
+	if err := rules.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	game, err := NewGame(nil, &stubClient{}, rules)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return game
+}
+
+// stubOf returns the stubClient a test game's agents play with, so a test can choose their actions
+func stubOf(game *Game) *stubClient {
+	return game.Agents[0].llm.(*recordingClient).client.(*RetryingClient).client.(*stubClient)
+}
+
+// errorCode returns the code of an action's error, or "" if it succeeded
+func errorCode(err error) string {
+	var actionErr *ActionError
+	if errors.As(err, &actionErr) {
+		return actionErr.Code
+	}
+
+	if err != nil {
+		return err.Error()
+	}
+
+	return ""
+}
@@ -0,0 +1,296 @@
+package main
+
+import (
+	"fmt"
+	"math"
+)
+
+// PolicyEvent is the event kind recorded when a vote is resolved
+const PolicyEvent = "policy"
+
+// Policy kinds
+const (
+	TaxRatePolicy           = "tax_rate"
+	WheatDecayRatePolicy    = "wheat_decay_rate"
+	SubsidiseBuildingPolicy = "subsidise_building"
+	DistributeWheatPolicy   = "distribute_wheat"
+)
+
+// Policy statuses
+const (
+	PolicyPending  = "pending"
+	PolicyPassed   = "passed"
+	PolicyRejected = "rejected"
+)
+
+// Treasury holds the taxes collected from every agent's production
+type Treasury struct {
+	Gold  int
+	Wheat int
+}
+
+// Policy is a proposal put to a vote of all agents
+type Policy struct {
+	ID           int
+	Turn         int
+	ProposedBy   int
+	Kind         string
+	Value        float64
+	BuildingType string
+	Votes        map[int]bool
+	Status       string
+}
+
+func (p Policy) String() string {
+	switch p.Kind {
+	case TaxRatePolicy:
+		return fmt.Sprintf("policy %d: set the tax rate to %.2f", p.ID, p.Value)
+	case WheatDecayRatePolicy:
+		return fmt.Sprintf("policy %d: set the wheat decay rate to %.2f", p.ID, p.Value)
+	case SubsidiseBuildingPolicy:
+		return fmt.Sprintf("policy %d: spend treasury gold on a %s for the poorest agent", p.ID, p.BuildingType)
+	case DistributeWheatPolicy:
+		return fmt.Sprintf("policy %d: share the treasury's wheat between the remaining agents", p.ID)
+	}
+
+	return fmt.Sprintf("policy %d: %s", p.ID, p.Kind)
+}
+
+// collectTax takes the tax on an amount of production, paying it into the treasury
+func (g *Game) collectTax(resourceType string, produced int) int {
+	if !g.Rules.Governance {
+		return 0
+	}
+
+	tax := int(math.Round(float64(produced) * g.Rules.TaxRate))
+	if tax <= 0 {
+		return 0
+	}
+
+	switch resourceType {
+	case Gold:
+		g.Treasury.Gold += tax
+	case Wheat:
+		g.Treasury.Wheat += tax
+	}
+
+	return tax
+}
+
+// governanceState describes the treasury and open votes for an agent's game state
+func (g *Game) governanceState(agentID int) string {
+	if !g.Rules.Governance {
+		return ""
+	}
+
+	state := fmt.Sprintf("Treasury: %d gold, %d wheat (tax rate %.2f)\n", g.Treasury.Gold, g.Treasury.Wheat, g.Rules.TaxRate)
+	for _, policy := range g.Policies {
+		if policy.Status != PolicyPending {
+			continue
+		}
+
+		vote := "you have not voted"
+		if support, ok := policy.Votes[agentID]; ok {
+			vote = fmt.Sprintf("you voted %s", voteString(support))
+		}
+
+		state += fmt.Sprintf("Open vote on %s, proposed by Agent %d (%s)\n", policy, policy.ProposedBy, vote)
+	}
+
+	return state
+}
+
+func voteString(support bool) string {
+	if support {
+		return "for"
+	}
+
+	return "against"
+}
+
+// ProposePolicy puts a policy to a vote of all agents. The proposer votes for it.
+func (a *Agent) ProposePolicy(g *Game, kind string, value float64, buildingType string) error {
+	a.AddTurnLog(fmt.Sprintf("Attempting to propose a %s policy", kind))
+
+	switch kind {
+	case TaxRatePolicy:
+		if value < 0 || value > 1 {
+			return actionError(ErrInvalidPolicy, "Failed to propose policy, the tax rate must be between 0 and 1")
+		}
+	case WheatDecayRatePolicy:
+		if value < 0 || value > 1 {
+			return actionError(ErrInvalidPolicy, "Failed to propose policy, the wheat decay rate must be between 0 and 1")
+		}
+	case SubsidiseBuildingPolicy:
+		if buildingType != Farm && buildingType != Mine {
+			return actionError(ErrInvalidPolicy, "Failed to propose policy, unknown building type %s", buildingType)
+		}
+	case DistributeWheatPolicy:
+		// Sharing out the wheat needs no arguments
+	default:
+		return actionError(ErrInvalidPolicy, "Failed to propose policy, unknown policy kind %s", kind)
+	}
+
+	g.nextPolicyID++
+	policy := Policy{
+		ID:           g.nextPolicyID,
+		Turn:         g.CurrentTurn,
+		ProposedBy:   a.ID,
+		Kind:         kind,
+		Value:        value,
+		BuildingType: buildingType,
+		Votes:        map[int]bool{a.ID: true},
+		Status:       PolicyPending,
+	}
+	g.Policies = append(g.Policies, policy)
+
+	a.AddTurnLog(fmt.Sprintf("Proposed %s", policy))
+	g.broadcastMessage(fmt.Sprintf("Agent %d has proposed %s. Use the vote tool to vote on it.", a.ID, policy), a.ID)
+
+	return nil
+}
+
+// Vote records the agent's vote on an open policy. Agents may change their vote until it is resolved.
+func (a *Agent) Vote(g *Game, policyID int, support bool) error {
+	for i := range g.Policies {
+		if g.Policies[i].ID != policyID {
+			continue
+		}
+
+		if g.Policies[i].Status != PolicyPending {
+			return actionError(ErrNoSuchPolicy, "Failed to vote, policy %d has already been %s", policyID, g.Policies[i].Status)
+		}
+
+		g.Policies[i].Votes[a.ID] = support
+		a.AddTurnLog(fmt.Sprintf("Voted %s %s", voteString(support), g.Policies[i]))
+
+		return nil
+	}
+
+	return actionError(ErrNoSuchPolicy, "Failed to vote, policy %d does not exist", policyID)
+}
+
+// ResolvePolicies closes votes at the end of a round. A policy is resolved once every remaining
+// agent has voted on it or it has been open for a full round, and passes with a strict majority
+// of remaining agents.
+func (g *Game) ResolvePolicies() {
+	if !g.Rules.Governance {
+		return
+	}
+
+	voters := 0
+	for _, agent := range g.Agents {
+		if !agent.Lost {
+			voters++
+		}
+	}
+
+	for i := range g.Policies {
+		policy := &g.Policies[i]
+		if policy.Status != PolicyPending {
+			continue
+		}
+
+		votesFor, votesCast := 0, 0
+		for agentID, support := range policy.Votes {
+			if g.Agents[agentID].Lost {
+				continue
+			}
+
+			votesCast++
+			if support {
+				votesFor++
+			}
+		}
+
+		if votesCast < voters && policy.Turn == g.CurrentTurn {
+			continue
+		}
+
+		if votesFor*2 <= voters {
+			policy.Status = PolicyRejected
+			msg := fmt.Sprintf("The vote on %s was rejected with %d of %d votes in favour", policy, votesFor, voters)
+			g.broadcastMessage(msg, -1)
+			g.recordEvent(PolicyEvent, policy.ProposedBy, -1, false, msg)
+
+			continue
+		}
+
+		policy.Status = PolicyPassed
+		msg := fmt.Sprintf("The vote on %s passed with %d of %d votes in favour. %s", policy, votesFor, voters, g.enactPolicy(*policy))
+		g.broadcastMessage(msg, -1)
+		g.recordEvent(PolicyEvent, policy.ProposedBy, -1, true, msg)
+	}
+}
+
+// enactPolicy applies a policy that has passed, returning a description of its effect
+func (g *Game) enactPolicy(policy Policy) string {
+	switch policy.Kind {
+	case TaxRatePolicy:
+		g.Rules.TaxRate = policy.Value
+		return fmt.Sprintf("The tax rate is now %.2f.", g.Rules.TaxRate)
+	case WheatDecayRatePolicy:
+		g.Rules.WheatDecayRate = policy.Value
+		return fmt.Sprintf("Wheat now decays at %.2f per turn.", g.Rules.WheatDecayRate)
+	case SubsidiseBuildingPolicy:
+		cost := g.Rules.FarmCost
+		if policy.BuildingType == Mine {
+			cost = g.Rules.MineCost
+		}
+
+		if g.Treasury.Gold < cost {
+			return fmt.Sprintf("The treasury cannot afford the %d gold %s.", cost, policy.BuildingType)
+		}
+
+		poorest := g.poorestAgent()
+		if poorest == nil {
+			return "There is no agent to receive the subsidy."
+		}
+
+		g.Treasury.Gold -= cost
+		poorest.Buildings = append(poorest.Buildings, Building{Type: policy.BuildingType, Manned: false})
+
+		return fmt.Sprintf("The treasury paid %d gold for a %s for Agent %d.", cost, policy.BuildingType, poorest.ID)
+	case DistributeWheatPolicy:
+		remaining := []*Agent{}
+		for i := range g.Agents {
+			if !g.Agents[i].Lost {
+				remaining = append(remaining, &g.Agents[i])
+			}
+		}
+
+		if len(remaining) == 0 {
+			return "There is no agent to receive the wheat."
+		}
+
+		share := g.Treasury.Wheat / len(remaining)
+		if share == 0 {
+			return fmt.Sprintf("The treasury's %d wheat is too little to share.", g.Treasury.Wheat)
+		}
+
+		for _, agent := range remaining {
+			agent.Wheat += share
+		}
+		g.Treasury.Wheat -= share * len(remaining)
+
+		return fmt.Sprintf("The treasury gave %d wheat to each remaining agent.", share)
+	}
+
+	return ""
+}
+
+// poorestAgent returns the remaining agent with the least gold, breaking ties by lowest ID
+func (g *Game) poorestAgent() *Agent {
+	var poorest *Agent
+	for i := range g.Agents {
+		if g.Agents[i].Lost {
+			continue
+		}
+
+		if poorest == nil || g.Agents[i].Gold < poorest.Gold {
+			poorest = &g.Agents[i]
+		}
+	}
+
+	return poorest
+}
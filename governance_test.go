@@ -0,0 +1,168 @@
+package main
+
+import "testing"
+
+// withGovernance configures a game of three agents who vote on policies, taxed at 20%
+func withGovernance(r *Ruleset) {
+	r.NumAgents = 3
+	r.Governance = true
+	r.TaxRate = 0.2
+}
+
+func TestProductionIsTaxed(t *testing.T) {
+	game := newTestGame(t, withGovernance)
+	agent := &game.Agents[0]
+	agent.Gold, agent.Wheat = 0, 0
+	agent.Buildings = []Building{{Type: Farm, Manned: true}, {Type: Farm, Manned: true}, {Type: Mine, Manned: true}}
+
+	agent.ProduceResources(game)
+
+	// 6 wheat and 5 gold are produced, and a fifth of each (rounded) goes to the treasury
+	if agent.Wheat != 5 || agent.Gold != 4 {
+		t.Errorf("agent kept %d wheat and %d gold, want 5 and 4", agent.Wheat, agent.Gold)
+	}
+
+	if game.Treasury != (Treasury{Gold: 1, Wheat: 1}) {
+		t.Errorf("treasury holds %+v, want 1 gold and 1 wheat", game.Treasury)
+	}
+
+	game.Rules.Governance = false
+	agent.ProduceResources(game)
+	if game.Treasury != (Treasury{Gold: 1, Wheat: 1}) {
+		t.Errorf("production was taxed with governance disabled, treasury holds %+v", game.Treasury)
+	}
+}
+
+func TestResolvePolicies(t *testing.T) {
+	tests := []struct {
+		name   string
+		kind   string
+		value  float64
+		votes  []bool
+		passed bool
+		check  func(t *testing.T, g *Game)
+	}{
+		{
+			name: "tax rate passes with a majority", kind: TaxRatePolicy, value: 0.5, votes: []bool{true, false},
+			passed: true,
+			check: func(t *testing.T, g *Game) {
+				if g.Rules.TaxRate != 0.5 {
+					t.Errorf("tax rate is %v, want 0.5", g.Rules.TaxRate)
+				}
+			},
+		},
+		{
+			name: "wheat decay rejected without a majority", kind: WheatDecayRatePolicy, value: 0.5, votes: []bool{false, false},
+			check: func(t *testing.T, g *Game) {
+				if g.Rules.WheatDecayRate != WheatDecayRate {
+					t.Errorf("wheat decay rate is %v, want it unchanged", g.Rules.WheatDecayRate)
+				}
+			},
+		},
+		{
+			name: "subsidy for the poorest agent", kind: SubsidiseBuildingPolicy, votes: []bool{true, true},
+			passed: true,
+			check: func(t *testing.T, g *Game) {
+				if len(g.Agents[1].Buildings) != 1 || g.Agents[1].Buildings[0].Type != Mine {
+					t.Errorf("poorest agent has buildings %+v, want one Mine", g.Agents[1].Buildings)
+				}
+				if g.Treasury.Gold != 100-MineCost {
+					t.Errorf("treasury has %d gold, want %d", g.Treasury.Gold, 100-MineCost)
+				}
+			},
+		},
+		{
+			name: "treasury wheat shared between remaining agents", kind: DistributeWheatPolicy, votes: []bool{true, true},
+			passed: true,
+			check: func(t *testing.T, g *Game) {
+				for _, agent := range g.Agents {
+					if agent.Wheat != 33 {
+						t.Errorf("Agent %d has %d wheat, want 33", agent.ID, agent.Wheat)
+					}
+				}
+				if g.Treasury.Wheat != 1 {
+					t.Errorf("treasury has %d wheat left, want 1", g.Treasury.Wheat)
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			game := newTestGame(t, withGovernance)
+			game.Treasury = Treasury{Gold: 100, Wheat: 100}
+			for i := range game.Agents {
+				game.Agents[i].Gold = 50 - i*10
+				game.Agents[i].Wheat = 0
+			}
+			game.Agents[2].Gold = 100
+
+			if err := game.Agents[0].ProposePolicy(game, tt.kind, tt.value, Mine); err != nil {
+				t.Fatal(err)
+			}
+			for i, support := range tt.votes {
+				if err := game.Agents[i+1].Vote(game, game.Policies[0].ID, support); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			game.ResolvePolicies()
+
+			want := PolicyRejected
+			if tt.passed {
+				want = PolicyPassed
+			}
+			if game.Policies[0].Status != want {
+				t.Fatalf("policy is %s, want %s", game.Policies[0].Status, want)
+			}
+
+			tt.check(t, game)
+		})
+	}
+}
+
+// TestPolicyStaysOpenForARound keeps a vote open until every agent has voted or a round has passed
+func TestPolicyStaysOpenForARound(t *testing.T) {
+	game := newTestGame(t, withGovernance)
+
+	if err := game.Agents[0].ProposePolicy(game, TaxRatePolicy, 0.5, ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := game.Agents[1].Vote(game, 1, true); err != nil {
+		t.Fatal(err)
+	}
+
+	game.ResolvePolicies()
+	if game.Policies[0].Status != PolicyPending {
+		t.Fatalf("policy is %s before Agent 2 voted, want pending", game.Policies[0].Status)
+	}
+
+	game.CurrentTurn++
+	game.ResolvePolicies()
+	if game.Policies[0].Status != PolicyPassed {
+		t.Fatalf("policy is %s a round later, want passed", game.Policies[0].Status)
+	}
+
+	if err := game.Agents[2].Vote(game, 1, false); errorCode(err) != ErrNoSuchPolicy {
+		t.Errorf("voting on a resolved policy returned %v, want %s", err, ErrNoSuchPolicy)
+	}
+}
+
+func TestProposePolicyChecksValues(t *testing.T) {
+	game := newTestGame(t, withGovernance)
+
+	for _, policy := range []Policy{
+		{Kind: TaxRatePolicy, Value: 1.5},
+		{Kind: WheatDecayRatePolicy, Value: -0.1},
+		{Kind: SubsidiseBuildingPolicy, BuildingType: "Castle"},
+		{Kind: "abolish_wheat"},
+	} {
+		if err := game.Agents[0].ProposePolicy(game, policy.Kind, policy.Value, policy.BuildingType); errorCode(err) != ErrInvalidPolicy {
+			t.Errorf("proposing %s returned %v, want %s", policy, err, ErrInvalidPolicy)
+		}
+	}
+
+	if len(game.Policies) != 0 {
+		t.Errorf("accepted invalid policies: %+v", game.Policies)
+	}
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math"
+	"math/rand"
+	"slices"
+)
+
+// Information models
+const (
+	// ExactInformation shows agents each other's holdings as they are
+	ExactInformation = "exact"
+	// DelayedInformation shows agents each other's holdings as they were InformationDelay rounds ago
+	DelayedInformation = "delayed"
+	// NoisyInformation adds a seeded error of up to InformationNoise to each figure
+	NoisyInformation = "noisy"
+	// HiddenInformation hides other agents' holdings altogether
+	HiddenInformation = "hidden"
+)
+
+// recordHoldings snapshots every agent's holdings at the start of the round, for delayed information
+func (g *Game) recordHoldings() {
+	holdings := make([]State, len(g.Agents))
+	for i := range g.Agents {
+		holdings[i] = g.Agents[i].state()
+		holdings[i].Buildings = slices.Clone(holdings[i].Buildings)
+	}
+
+	g.holdings = append(g.holdings, holdings)
+}
+
+// seesExactly reports whether an agent sees another's holdings as they are, whatever the
+// information model: agents always see themselves, and allies who share visibility each other
+func (g *Game) seesExactly(agentID int, targetID int) bool {
+	return agentID == targetID || (g.Rules.AllianceSharedVisibility && slices.Contains(g.allies(agentID), targetID))
+}
+
+// observe returns what an agent can see of another agent's holdings under the information model,
+// and the round the figures date from. It reports false when the holdings are hidden.
+func (g *Game) observe(agentID int, targetID int) (State, int, bool) {
+	target := &g.Agents[targetID]
+
+	if g.seesExactly(agentID, targetID) {
+		return target.state(), g.CurrentTurn, true
+	}
+
+	switch g.Rules.InformationModel {
+	case DelayedInformation:
+		round := min(max(g.CurrentTurn-g.Rules.InformationDelay, 0), len(g.holdings)-1)
+		if round < 0 {
+			return target.state(), g.CurrentTurn, true
+		}
+
+		return g.holdings[round][targetID], round, true
+	case NoisyInformation:
+		state := target.state()
+		state.Gold = g.noisy(state.Gold, agentID, targetID, Gold)
+		state.Wheat = g.noisy(state.Wheat, agentID, targetID, Wheat)
+		state.Workers = g.noisy(state.Workers, agentID, targetID, "Workers")
+		state.Walls = g.noisy(state.Walls, agentID, targetID, "Walls")
+		state.Guards = g.noisy(state.Guards, agentID, targetID, "Guards")
+
+		return state, g.CurrentTurn, true
+	case HiddenInformation:
+		return State{}, g.CurrentTurn, false
+	}
+
+	return target.state(), g.CurrentTurn, true
+}
+
+// noisy adds a random error of up to InformationNoise to a figure. The error is seeded by the game,
+// round, observer, target and figure, so the same question asked again in a round gets the same
+// answer, and replays see the same errors.
+func (g *Game) noisy(value int, agentID int, targetID int, figure string) int {
+	h := fnv.New64a()
+	fmt.Fprintf(h, "%d/%d/%d/%d/%s", g.Seed, g.CurrentTurn, agentID, targetID, figure)
+	rng := rand.New(rand.NewSource(int64(h.Sum64())))
+
+	err := (rng.Float64()*2 - 1) * g.Rules.InformationNoise
+
+	return max(int(math.Round(float64(value)*(1+err))), 0)
+}
+
+// holdingsReport is what an agent is told of another agent's holdings when they end their turn
+func (g *Game) holdingsReport(agentID int, targetID int) string {
+	state, round, ok := g.observe(agentID, targetID)
+	if !ok {
+		return fmt.Sprintf("Agent %d has ended their turn", targetID)
+	}
+
+	holdings := fmt.Sprintf("%d gold, %d wheat, %d workers, %d walls, %d guards, and %d buildings",
+		state.Gold, state.Wheat, state.Workers, state.Walls, state.Guards, len(state.Buildings))
+
+	if !g.seesExactly(agentID, targetID) {
+		switch g.Rules.InformationModel {
+		case DelayedInformation:
+			return fmt.Sprintf("Agent %d has ended their turn. At the start of round %d they had %s", targetID, round+1, holdings)
+		case NoisyInformation:
+			return fmt.Sprintf("Agent %d has ended their turn with roughly %s", targetID, holdings)
+		}
+	}
+
+	return fmt.Sprintf("Agent %d has ended their turn with %s", targetID, holdings)
+}
+
+// observationNote qualifies figures an agent sees of another agent that are not exact
+func (g *Game) observationNote(agentID int, targetID int, round int) string {
+	if g.seesExactly(agentID, targetID) {
+		return ""
+	}
+
+	switch g.Rules.InformationModel {
+	case DelayedInformation:
+		return fmt.Sprintf(" (as of the start of round %d)", round+1)
+	case NoisyInformation:
+		return " (rough figures)"
+	}
+
+	return ""
+}
+
+// broadcastHoldings tells every other agent what they can see of an agent's holdings
+func (g *Game) broadcastHoldings(agentID int) {
+	for i := range g.Agents {
+		if i != agentID {
+			g.Agents[i].notify(g.holdingsReport(i, agentID))
+		}
+	}
+}
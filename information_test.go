@@ -0,0 +1,71 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func withInformation(model string) func(*Ruleset) {
+	return func(r *Ruleset) {
+		r.NumAgents = 3
+		r.InformationModel = model
+		r.Seed = 5
+	}
+}
+
+func TestDelayedInformation(t *testing.T) {
+	game := newTestGame(t, withInformation(DelayedInformation))
+	target := &game.Agents[1]
+
+	for round := 0; round < 4; round++ {
+		game.CurrentTurn = round
+		target.Gold = 100 * (round + 1)
+		game.recordHoldings()
+	}
+
+	state, round, ok := game.observe(0, target.ID)
+	if !ok || round != 1 || state.Gold != 200 {
+		t.Errorf("observed %d gold from round %d, want 200 from round 1", state.Gold, round)
+	}
+
+	// Agents always see themselves as they are
+	if state, _, _ := game.observe(target.ID, target.ID); state.Gold != 400 {
+		t.Errorf("Agent 1 sees themselves with %d gold, want 400", state.Gold)
+	}
+}
+
+func TestNoisyInformation(t *testing.T) {
+	game := newTestGame(t, withInformation(NoisyInformation))
+	game.Agents[1].Gold = 1000
+
+	first, _, _ := game.observe(0, 1)
+	if first.Gold < 800 || first.Gold > 1200 {
+		t.Errorf("observed %d gold, want within %v of 1000", first.Gold, InformationNoise)
+	}
+
+	// Asking again in the same round gets the same answer
+	if again, _, _ := game.observe(0, 1); again.Gold != first.Gold {
+		t.Errorf("observed %d gold then %d in the same round", first.Gold, again.Gold)
+	}
+
+	// Allies who share visibility see each other exactly
+	game.Rules.AllianceSharedVisibility = true
+	ally(t, game, 0, 1)
+	if state, _, _ := game.observe(0, 1); state.Gold != 1000 {
+		t.Errorf("ally observed %d gold, want 1000", state.Gold)
+	}
+}
+
+func TestHiddenInformation(t *testing.T) {
+	game := newTestGame(t, withInformation(HiddenInformation))
+
+	if _, _, ok := game.observe(0, 1); ok {
+		t.Error("hidden holdings were observed")
+	}
+
+	game.broadcastHoldings(1)
+	last := game.Agents[0].Prompt[len(game.Agents[0].Prompt)-1].Content
+	if !strings.Contains(last, "Agent 1 has ended their turn") || strings.Contains(last, "gold") {
+		t.Errorf("Agent 0 was told %q", last)
+	}
+}
@@ -3,13 +3,12 @@ package main
 import (
 	"context"
 	"fmt"
+	"math"
 
 	openai "github.com/sashabaranov/go-openai"
 	jsonschema "github.com/sashabaranov/go-openai/jsonschema"
 )
 
-var tools = getToolDefinitions()
-
 func validateAPIKey(apiKey string) error {
 	client := openai.NewClient(apiKey)
 	r, err := client.ListModels(context.Background())
@@ -21,17 +20,30 @@ func validateAPIKey(apiKey string) error {
 	return nil
 }
 
-func getReasoningFromLM(prompt []openai.ChatCompletionMessage, key string) (string, error) {
-	client := openai.NewClient(key)
-	resp, err := client.CreateChatCompletion(
-		context.Background(),
-		openai.ChatCompletionRequest{
-			Model:      openai.GPT3Dot5Turbo,
-			Messages:   prompt,
-			Tools:      tools,
-			ToolChoice: "none",
-		},
-	)
+// ChatClient is the part of the OpenAI client the game uses, so that recorded
+// responses can stand in for the real model
+type ChatClient interface {
+	CreateChatCompletion(ctx context.Context, request openai.ChatCompletionRequest) (openai.ChatCompletionResponse, error)
+}
+
+// chatRequest builds a completion request for the agent's prompt using the game's sampling settings
+func (g *Game) chatRequest(messages []openai.ChatCompletionMessage) openai.ChatCompletionRequest {
+	// Derive the sampling seed from the game seed so providers that honour it sample repeatably
+	seed := int(g.Seed & math.MaxInt32)
+
+	return openai.ChatCompletionRequest{
+		Model:       g.Rules.Model,
+		Messages:    messages,
+		Tools:       g.tools,
+		Temperature: g.Rules.Temperature,
+		Seed:        &seed,
+	}
+}
+
+func getReasoningFromLM(ctx context.Context, client ChatClient, request openai.ChatCompletionRequest) (string, error) {
+	request.ToolChoice = "none"
+
+	resp, err := client.CreateChatCompletion(ctx, request)
 	if err != nil {
 		return "", fmt.Errorf("failed to create chat completion: %w", err)
 	}
@@ -43,34 +55,26 @@ func getReasoningFromLM(prompt []openai.ChatCompletionMessage, key string) (stri
 	return resp.Choices[0].Message.Content, nil
 }
 
-func getToolCall(messages []openai.ChatCompletionMessage, key string) (*openai.ToolCall, error) {
-	client := openai.NewClient(key)
-	resp, err := client.CreateChatCompletion(
-		context.Background(),
-		openai.ChatCompletionRequest{
-			Model:      openai.GPT3Dot5Turbo,
-			Messages:   messages,
-			Tools:      tools,
-			ToolChoice: "required",
-		},
-	)
+// getToolCalls returns the model's response containing one or more tool calls
+func getToolCalls(ctx context.Context, client ChatClient, request openai.ChatCompletionRequest) (openai.ChatCompletionMessage, error) {
+	request.ToolChoice = "required"
+
+	resp, err := client.CreateChatCompletion(ctx, request)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create chat completion: %w", err)
+		return openai.ChatCompletionMessage{}, fmt.Errorf("failed to create chat completion: %w", err)
 	}
 
 	if len(resp.Choices) == 0 {
-		return nil, fmt.Errorf("no choices returned")
+		return openai.ChatCompletionMessage{}, fmt.Errorf("no choices returned")
 	}
 
 	choice := resp.Choices[0]
 
 	if len(choice.Message.ToolCalls) == 0 {
-		return nil, fmt.Errorf("no tool calls returned")
+		return openai.ChatCompletionMessage{}, fmt.Errorf("no tool calls returned")
 	}
 
-	toolCall := choice.Message.ToolCalls[0]
-
-	return &toolCall, nil
+	return choice.Message, nil
 }
 
 func giveResourcesTool() openai.Tool {
@@ -86,13 +90,15 @@ func giveResourcesTool() openai.Tool {
 				Properties: map[string]jsonschema.Definition{
 					"type": {
 						Type:        jsonschema.String,
-						Description: "The type of resource to give (gold or wheat)",
+						Description: "The type of resource to give (Gold or Wheat)",
+						Enum:        []string{Gold, Wheat},
 					},
 					"amount": {
 						Type:        jsonschema.Integer,
 						Description: "The amount of the resource to give",
 					},
 				},
+				Required: []string{"type", "amount"},
 			},
 		},
 		Required: []string{"target_agent", "resource"},
@@ -145,6 +151,7 @@ func buyBuildingTool() openai.Tool {
 			"building_type": {
 				Type:        jsonschema.String,
 				Description: "The type of building to buy (Farm or Mine)",
+				Enum:        []string{Farm, Mine},
 			},
 		},
 		Required: []string{"building_type"},
@@ -198,6 +205,18 @@ func endTurnTool() openai.Tool {
 	}
 }
 
+func endNegotiationTool() openai.Tool {
+	f := openai.FunctionDefinition{
+		Name:        "end_negotiation",
+		Description: "Send no more messages in this round of negotiation",
+	}
+
+	return openai.Tool{
+		Type:     openai.ToolTypeFunction,
+		Function: &f,
+	}
+}
+
 func manBuildingTool() openai.Tool {
 	params := jsonschema.Definition{
 		Type: jsonschema.Object,
@@ -205,6 +224,7 @@ func manBuildingTool() openai.Tool {
 			"building_type": {
 				Type:        jsonschema.String,
 				Description: "The type of building that you want to allocate workers to",
+				Enum:        []string{Farm, Mine},
 			},
 		},
 		Required: []string{"building_type"},
@@ -229,6 +249,7 @@ func unmanBuildingTool() openai.Tool {
 			"building_type": {
 				Type:        jsonschema.String,
 				Description: "The type of building that you want to deallocate workers from",
+				Enum:        []string{Farm, Mine},
 			},
 		},
 		Required: []string{"building_type"},
@@ -246,14 +267,559 @@ func unmanBuildingTool() openai.Tool {
 	}
 }
 
-func getToolDefinitions() []openai.Tool {
-	return []openai.Tool{
+func raidTool() openai.Tool {
+	params := jsonschema.Definition{
+		Type: jsonschema.Object,
+		Properties: map[string]jsonschema.Definition{
+			"target_agent": {
+				Type:        jsonschema.Integer,
+				Description: "The ID of the agent to raid",
+			},
+			"workers": {
+				Type:        jsonschema.Integer,
+				Description: "The number of unoccupied workers to send on the raid. More workers improve the odds of success, but half of them are lost if the raid fails",
+			},
+		},
+		Required: []string{"target_agent", "workers"},
+	}
+
+	f := openai.FunctionDefinition{
+		Name:        "raid",
+		Description: "Spend gold and send workers to try and steal a share of another agent's gold and wheat",
+		Parameters:  params,
+	}
+
+	return openai.Tool{
+		Type:     openai.ToolTypeFunction,
+		Function: &f,
+	}
+}
+
+func sabotageBuildingTool() openai.Tool {
+	params := jsonschema.Definition{
+		Type: jsonschema.Object,
+		Properties: map[string]jsonschema.Definition{
+			"target_agent": {
+				Type:        jsonschema.Integer,
+				Description: "The ID of the agent whose building you want to sabotage",
+			},
+			"building_type": {
+				Type:        jsonschema.String,
+				Description: "The type of building to sabotage (Farm or Mine)",
+				Enum:        []string{Farm, Mine},
+			},
+		},
+		Required: []string{"target_agent", "building_type"},
+	}
+
+	f := openai.FunctionDefinition{
+		Name:        "sabotage_building",
+		Description: "Spend gold to try and disable another agent's building for several turns",
+		Parameters:  params,
+	}
+
+	return openai.Tool{
+		Type:     openai.ToolTypeFunction,
+		Function: &f,
+	}
+}
+
+func buildWallTool() openai.Tool {
+	f := openai.FunctionDefinition{
+		Name:        "build_wall",
+		Description: "Build a wall, permanently reducing the chance that raids and sabotage against you succeed",
+	}
+
+	return openai.Tool{
+		Type:     openai.ToolTypeFunction,
+		Function: &f,
+	}
+}
+
+func hireGuardsTool() openai.Tool {
+	params := jsonschema.Definition{
+		Type: jsonschema.Object,
+		Properties: map[string]jsonschema.Definition{
+			"count": {
+				Type:        jsonschema.Integer,
+				Description: "The number of guards to hire",
+			},
+		},
+		Required: []string{"count"},
+	}
+
+	f := openai.FunctionDefinition{
+		Name:        "hire_guards",
+		Description: "Hire guards, reducing the chance that raids and sabotage against you succeed. Guards eat wheat each turn and desert if unfed",
+		Parameters:  params,
+	}
+
+	return openai.Tool{
+		Type:     openai.ToolTypeFunction,
+		Function: &f,
+	}
+}
+
+func proposeAllianceTool() openai.Tool {
+	params := jsonschema.Definition{
+		Type: jsonschema.Object,
+		Properties: map[string]jsonschema.Definition{
+			"target_agent": {
+				Type:        jsonschema.Integer,
+				Description: "The ID of the agent to invite into an alliance with you",
+			},
+		},
+		Required: []string{"target_agent"},
+	}
+
+	f := openai.FunctionDefinition{
+		Name:        "propose_alliance",
+		Description: "Propose a formal alliance to another agent. If you are already in an alliance, they are invited to join it",
+		Parameters:  params,
+	}
+
+	return openai.Tool{
+		Type:     openai.ToolTypeFunction,
+		Function: &f,
+	}
+}
+
+func acceptAllianceTool() openai.Tool {
+	params := jsonschema.Definition{
+		Type: jsonschema.Object,
+		Properties: map[string]jsonschema.Definition{
+			"from_agent": {
+				Type:        jsonschema.Integer,
+				Description: "The ID of the agent whose alliance proposal you are accepting",
+			},
+		},
+		Required: []string{"from_agent"},
+	}
+
+	f := openai.FunctionDefinition{
+		Name:        "accept_alliance",
+		Description: "Accept a pending alliance proposal",
+		Parameters:  params,
+	}
+
+	return openai.Tool{
+		Type:     openai.ToolTypeFunction,
+		Function: &f,
+	}
+}
+
+func leaveAllianceTool() openai.Tool {
+	f := openai.FunctionDefinition{
+		Name:        "leave_alliance",
+		Description: "Leave your current alliance. Every agent will be told that you betrayed your allies",
+	}
+
+	return openai.Tool{
+		Type:     openai.ToolTypeFunction,
+		Function: &f,
+	}
+}
+
+func sendAllianceMessageTool() openai.Tool {
+	params := jsonschema.Definition{
+		Type: jsonschema.Object,
+		Properties: map[string]jsonschema.Definition{
+			"message": {
+				Type:        jsonschema.String,
+				Description: "The message to send to your allies",
+			},
+		},
+		Required: []string{"message"},
+	}
+
+	f := openai.FunctionDefinition{
+		Name:        "send_alliance_message",
+		Description: "Send a message on your alliance's private chat channel, which only your allies can read",
+		Parameters:  params,
+	}
+
+	return openai.Tool{
+		Type:     openai.ToolTypeFunction,
+		Function: &f,
+	}
+}
+
+func proposePolicyTool() openai.Tool {
+	params := jsonschema.Definition{
+		Type: jsonschema.Object,
+		Properties: map[string]jsonschema.Definition{
+			"kind": {
+				Type:        jsonschema.String,
+				Enum:        []string{TaxRatePolicy, WheatDecayRatePolicy, SubsidiseBuildingPolicy, DistributeWheatPolicy},
+				Description: "The kind of policy: change the tax rate, change the wheat decay rate, spend treasury gold on a building for the poorest agent, or share the treasury's wheat between the remaining agents",
+			},
+			"value": {
+				Type:        jsonschema.Number,
+				Description: "The new rate, between 0 and 1, for tax_rate and wheat_decay_rate policies",
+			},
+			"building_type": {
+				Type:        jsonschema.String,
+				Description: "The type of building to subsidise (Farm or Mine) for subsidise_building policies",
+			},
+		},
+		Required: []string{"kind"},
+	}
+
+	f := openai.FunctionDefinition{
+		Name:        "propose_policy",
+		Description: "Propose a policy that all agents vote on. It passes if a majority of remaining agents vote for it",
+		Parameters:  params,
+	}
+
+	return openai.Tool{
+		Type:     openai.ToolTypeFunction,
+		Function: &f,
+	}
+}
+
+func voteTool() openai.Tool {
+	params := jsonschema.Definition{
+		Type: jsonschema.Object,
+		Properties: map[string]jsonschema.Definition{
+			"policy_id": {
+				Type:        jsonschema.Integer,
+				Description: "The ID of the policy to vote on",
+			},
+			"support": {
+				Type:        jsonschema.Boolean,
+				Description: "True to vote for the policy, false to vote against it",
+			},
+		},
+		Required: []string{"policy_id", "support"},
+	}
+
+	f := openai.FunctionDefinition{
+		Name:        "vote",
+		Description: "Vote on an open policy",
+		Parameters:  params,
+	}
+
+	return openai.Tool{
+		Type:     openai.ToolTypeFunction,
+		Function: &f,
+	}
+}
+
+func writeNoteTool() openai.Tool {
+	params := jsonschema.Definition{
+		Type: jsonschema.Object,
+		Properties: map[string]jsonschema.Definition{
+			"note": {
+				Type:        jsonschema.String,
+				Description: "The note to write, e.g. a plan, or a deal or promise to check up on",
+			},
+		},
+		Required: []string{"note"},
+	}
+
+	f := openai.FunctionDefinition{
+		Name:        "write_note",
+		Description: "Write a note in your private notebook, which only you can see and which you will always be shown. Does not use an action.",
+		Parameters:  params,
+	}
+
+	return openai.Tool{
+		Type:     openai.ToolTypeFunction,
+		Function: &f,
+	}
+}
+
+func readNotesTool() openai.Tool {
+	f := openai.FunctionDefinition{
+		Name:        "read_notes",
+		Description: "Read every note in your private notebook. Does not use an action.",
+	}
+
+	return openai.Tool{
+		Type:     openai.ToolTypeFunction,
+		Function: &f,
+	}
+}
+
+func inspectAgentTool() openai.Tool {
+	params := jsonschema.Definition{
+		Type: jsonschema.Object,
+		Properties: map[string]jsonschema.Definition{
+			"target_agent": {
+				Type:        jsonschema.Integer,
+				Description: "The ID of the agent to inspect",
+			},
+		},
+		Required: []string{"target_agent"},
+	}
+
+	f := openai.FunctionDefinition{
+		Name:        "inspect_agent",
+		Description: "Look up another agent's resources, buildings and alliance, as far as you can see them. Does not use an action.",
+		Parameters:  params,
+	}
+
+	return openai.Tool{
+		Type:     openai.ToolTypeFunction,
+		Function: &f,
+	}
+}
+
+func listBuildingsTool() openai.Tool {
+	f := openai.FunctionDefinition{
+		Name:        "list_buildings",
+		Description: "List every agent's buildings, as far as you can see them. Does not use an action.",
+	}
+
+	return openai.Tool{
+		Type:     openai.ToolTypeFunction,
+		Function: &f,
+	}
+}
+
+func viewPriceHistoryTool() openai.Tool {
+	params := jsonschema.Definition{
+		Type: jsonschema.Object,
+		Properties: map[string]jsonschema.Definition{
+			"turns": {
+				Type:        jsonschema.Integer,
+				Description: "Only show this many of the most recent rounds. Leave out to show them all",
+			},
+		},
+	}
+
+	f := openai.FunctionDefinition{
+		Name:        "view_price_history",
+		Description: "View the costs, production and wheat decay you have faced in each round. Does not use an action.",
+		Parameters:  params,
+	}
+
+	return openai.Tool{
+		Type:     openai.ToolTypeFunction,
+		Function: &f,
+	}
+}
+
+func viewMyLedgerTool() openai.Tool {
+	params := jsonschema.Definition{
+		Type: jsonschema.Object,
+		Properties: map[string]jsonschema.Definition{
+			"turns": {
+				Type:        jsonschema.Integer,
+				Description: "Only show this many of your most recent turns. Leave out to show them all",
+			},
+		},
+	}
+
+	f := openai.FunctionDefinition{
+		Name:        "view_my_ledger",
+		Description: "View how your holdings changed and which actions you took in each of your past turns, and the attacks made against you. Does not use an action.",
+		Parameters:  params,
+	}
+
+	return openai.Tool{
+		Type:     openai.ToolTypeFunction,
+		Function: &f,
+	}
+}
+
+func makePromiseTool() openai.Tool {
+	params := jsonschema.Definition{
+		Type: jsonschema.Object,
+		Properties: map[string]jsonschema.Definition{
+			"target_agent": {
+				Type:        jsonschema.Integer,
+				Description: "The ID of the agent you promise to give resources to",
+			},
+			"resource": {
+				Type: jsonschema.Object,
+				Properties: map[string]jsonschema.Definition{
+					"type": {
+						Type:        jsonschema.String,
+						Description: "The type of resource you promise to give (Gold or Wheat)",
+						Enum:        []string{Gold, Wheat},
+					},
+					"amount": {
+						Type:        jsonschema.Integer,
+						Description: "The amount of the resource you promise to give",
+					},
+				},
+				Required: []string{"type", "amount"},
+			},
+			"deadline_round": {
+				Type:        jsonschema.Integer,
+				Description: "The round by the end of which you will have given it",
+			},
+		},
+		Required: []string{"target_agent", "resource", "deadline_round"},
+	}
+
+	f := openai.FunctionDefinition{
+		Name:        "make_promise",
+		Description: "Promise to give another agent resources by the end of a round. The agent is told of your promise, and whether you keep it is tracked against the resources you give them",
+		Parameters:  params,
+	}
+
+	return openai.Tool{
+		Type:     openai.ToolTypeFunction,
+		Function: &f,
+	}
+}
+
+func broadcastMessageTool() openai.Tool {
+	params := jsonschema.Definition{
+		Type: jsonschema.Object,
+		Properties: map[string]jsonschema.Definition{
+			"message": {
+				Type:        jsonschema.String,
+				Description: "The message to post",
+			},
+		},
+		Required: []string{"message"},
+	}
+
+	f := openai.FunctionDefinition{
+		Name:        "broadcast_message",
+		Description: "Post a message in the town square, the public channel that every agent can read",
+		Parameters:  params,
+	}
+
+	return openai.Tool{
+		Type:     openai.ToolTypeFunction,
+		Function: &f,
+	}
+}
+
+func createGroupChatTool() openai.Tool {
+	params := jsonschema.Definition{
+		Type: jsonschema.Object,
+		Properties: map[string]jsonschema.Definition{
+			"name": {
+				Type:        jsonschema.String,
+				Description: "A name for the group chat",
+			},
+			"members": {
+				Type:        jsonschema.Array,
+				Description: "The IDs of the agents to add to the group chat, not including yourself",
+				Items:       &jsonschema.Definition{Type: jsonschema.Integer},
+			},
+		},
+		Required: []string{"name", "members"},
+	}
+
+	f := openai.FunctionDefinition{
+		Name:        "create_group_chat",
+		Description: "Create a group chat with other agents. Only its members can read it, and any member can invite more agents",
+		Parameters:  params,
+	}
+
+	return openai.Tool{
+		Type:     openai.ToolTypeFunction,
+		Function: &f,
+	}
+}
+
+func inviteToChannelTool() openai.Tool {
+	params := jsonschema.Definition{
+		Type: jsonschema.Object,
+		Properties: map[string]jsonschema.Definition{
+			"channel_id": {
+				Type:        jsonschema.Integer,
+				Description: "The ID of the group chat",
+			},
+			"target_agent": {
+				Type:        jsonschema.Integer,
+				Description: "The ID of the agent to invite",
+			},
+		},
+		Required: []string{"channel_id", "target_agent"},
+	}
+
+	f := openai.FunctionDefinition{
+		Name:        "invite_to_channel",
+		Description: "Add another agent to a group chat you are a member of",
+		Parameters:  params,
+	}
+
+	return openai.Tool{
+		Type:     openai.ToolTypeFunction,
+		Function: &f,
+	}
+}
+
+func postToChannelTool() openai.Tool {
+	params := jsonschema.Definition{
+		Type: jsonschema.Object,
+		Properties: map[string]jsonschema.Definition{
+			"channel_id": {
+				Type:        jsonschema.Integer,
+				Description: "The ID of the channel to post to. The town square is channel 0",
+			},
+			"message": {
+				Type:        jsonschema.String,
+				Description: "The message to post",
+			},
+		},
+		Required: []string{"channel_id", "message"},
+	}
+
+	f := openai.FunctionDefinition{
+		Name:        "post_to_channel",
+		Description: "Post a message on a channel you are a member of, which every other member will read",
+		Parameters:  params,
+	}
+
+	return openai.Tool{
+		Type:     openai.ToolTypeFunction,
+		Function: &f,
+	}
+}
+
+func listChannelsTool() openai.Tool {
+	f := openai.FunctionDefinition{
+		Name:        "list_channels",
+		Description: "List the channels you are a member of and who else is in them. Does not use an action.",
+	}
+
+	return openai.Tool{
+		Type:     openai.ToolTypeFunction,
+		Function: &f,
+	}
+}
+
+func getToolDefinitions(rules Ruleset) []openai.Tool {
+	tools := []openai.Tool{
 		giveResourcesTool(),
 		sendMessageTool(),
+		makePromiseTool(),
+		broadcastMessageTool(),
+		createGroupChatTool(),
+		inviteToChannelTool(),
+		postToChannelTool(),
+		listChannelsTool(),
 		buyBuildingTool(),
 		buyWorkerTool(),
 		manBuildingTool(),
 		unmanBuildingTool(),
-		endTurnTool(),
+		raidTool(),
+		sabotageBuildingTool(),
+		buildWallTool(),
+		hireGuardsTool(),
+		proposeAllianceTool(),
+		acceptAllianceTool(),
+		leaveAllianceTool(),
+		sendAllianceMessageTool(),
+		writeNoteTool(),
+		readNotesTool(),
+		inspectAgentTool(),
+		listBuildingsTool(),
+		viewPriceHistoryTool(),
+		viewMyLedgerTool(),
 	}
+
+	if rules.Governance {
+		tools = append(tools, proposePolicyTool(), voteTool())
+	}
+
+	return append(tools, endTurnTool())
 }
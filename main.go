@@ -4,9 +4,12 @@ import (
 	"fmt"
 	"net/http"
 	"os"
+	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/gorilla/websocket"
+	openai "github.com/sashabaranov/go-openai"
 )
 
 // Define the WebSocket upgrader
@@ -18,18 +21,38 @@ var upgrader = websocket.Upgrader{
 	},
 }
 
+// The ruleset used for every game started by this server
+var ruleset = DefaultRuleset()
+
+// If set, every game replays this recording instead of calling the model
+var replay *GameRecord
+
+// If set, a record of every game is saved to this directory when it ends
+var recordDir string
+
+// If set, a Markdown and HTML report of every game is saved to this directory when it ends
+var reportDir string
+
+// If set, model responses are cached in this directory, in cacheMode
+var cacheDir, cacheMode string
+
 // WebSocket handler
 func wsHandler(w http.ResponseWriter, r *http.Request) {
+	// Replays only use recorded responses, so they don't need a key
+	offline := replay != nil || (cacheDir != "" && cacheMode == CacheReplay)
+
 	openAIapiKey := r.URL.Query().Get("api_key")
-	if openAIapiKey == "" {
+	if openAIapiKey == "" && !offline {
 		http.Error(w, "API key is required", http.StatusUnauthorized)
 		return
 	}
 
-	err := validateAPIKey(openAIapiKey)
-	if err != nil {
-		http.Error(w, "Invalid API key", http.StatusUnauthorized)
-		return
+	if !offline {
+		err := validateAPIKey(openAIapiKey)
+		if err != nil {
+			http.Error(w, "Invalid API key", http.StatusUnauthorized)
+			return
+		}
 	}
 
 	// Upgrade HTTP request to a WebSocket connection
@@ -43,8 +66,27 @@ func wsHandler(w http.ResponseWriter, r *http.Request) {
 
 	defer conn.Close()
 
+	var client ChatClient = openai.NewClient(openAIapiKey)
+	if cacheDir != "" {
+		client, err = NewCachingClient(client, cacheDir, cacheMode)
+		if err != nil {
+			fmt.Println("Failed to open response cache:", err)
+			return
+		}
+	}
+
 	// Start a game
-	game := NewGame(conn, openAIapiKey)
+	game, err := NewGame(conn, client, ruleset)
+	if err != nil {
+		fmt.Println("Failed to start game:", err)
+		return
+	}
+	if replay != nil {
+		if err := game.Replay(*replay); err != nil {
+			fmt.Println("Failed to start replay:", err)
+			return
+		}
+	}
 
 	// Ping the client periodically to see if the connection is still alive
 	go func() {
@@ -68,12 +110,89 @@ func wsHandler(w http.ResponseWriter, r *http.Request) {
 	})
 
 	RunGame(game)
+	PrintGameResult(game)
+
+	name := fmt.Sprintf("game-%d", game.Seed)
+	if replay != nil {
+		name = fmt.Sprintf("game-%d-replay", game.Seed)
+	}
+
+	if recordDir != "" {
+		if err := game.SaveRecord(filepath.Join(recordDir, name+".json")); err != nil {
+			fmt.Println("Failed to save game record:", err)
+		}
+	}
+
+	if reportDir != "" {
+		if err := WriteReport(game.Record(), filepath.Join(reportDir, name+"-report")); err != nil {
+			fmt.Println("Failed to save game report:", err)
+		}
+	}
+}
+
+// writeReports writes a report next to each of the given game records, for games that have
+// already been played
+func writeReports(paths []string) error {
+	for _, path := range paths {
+		record, err := LoadRecord(path)
+		if err != nil {
+			return err
+		}
+
+		base := strings.TrimSuffix(path, filepath.Ext(path)) + "-report"
+		if err := WriteReport(record, base); err != nil {
+			return err
+		}
+
+		fmt.Printf("Wrote %s.md and %s.html\n", base, base)
+	}
+
+	return nil
 }
 
 func main() {
+	// "aconomy report <record.json>..." writes reports for recorded games instead of serving
+	if len(os.Args) > 1 && os.Args[1] == "report" {
+		if err := writeReports(os.Args[2:]); err != nil {
+			fmt.Println("Failed to write report:", err)
+			os.Exit(1)
+		}
+
+		return
+	}
+
 	// WebSocket endpoint
 	http.HandleFunc("/ws", wsHandler)
 
+	if path := os.Getenv("RULESET_PATH"); path != "" {
+		rules, err := LoadRuleset(path)
+		if err != nil {
+			fmt.Println("Failed to load ruleset:", err)
+			os.Exit(1)
+		}
+
+		ruleset = rules
+	}
+
+	if path := os.Getenv("REPLAY_PATH"); path != "" {
+		record, err := LoadRecord(path)
+		if err != nil {
+			fmt.Println("Failed to load game record:", err)
+			os.Exit(1)
+		}
+
+		replay = &record
+		ruleset = record.Rules
+	}
+
+	recordDir = os.Getenv("RECORD_DIR")
+	reportDir = os.Getenv("REPORT_DIR")
+
+	cacheDir, cacheMode = os.Getenv("LLM_CACHE_DIR"), os.Getenv("LLM_CACHE_MODE")
+	if cacheMode == "" {
+		cacheMode = CacheRecordMissing
+	}
+
 	port := os.Getenv("WEBSOCKET_PORT")
 
 	fmt.Printf("Server running on port %s\n", port)
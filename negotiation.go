@@ -0,0 +1,142 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+// negotiationTools are the tools agents can use while negotiating
+var negotiationTools = map[string]bool{
+	"send_message":          true,
+	"send_alliance_message": true,
+	"broadcast_message":     true,
+	"post_to_channel":       true,
+	"create_group_chat":     true,
+	"invite_to_channel":     true,
+	"make_promise":          true,
+}
+
+// negotiationToolDefinitions returns the tools offered while negotiating: the game's messaging
+// tools and end_negotiation
+func (g *Game) negotiationToolDefinitions() []openai.Tool {
+	tools := []openai.Tool{}
+	for _, tool := range g.tools {
+		if tool.Function != nil && negotiationTools[tool.Function.Name] {
+			tools = append(tools, tool)
+		}
+	}
+
+	return append(tools, endNegotiationTool())
+}
+
+// negotiate gives agents NegotiationRounds rounds of messages before anyone acts this round, so
+// they can make deals and answer each other without spending actions. Agents negotiate one after
+// another in turn order, in either turn mode, so each sees the messages sent before them. The
+// phase ends early once a round of negotiation passes without any messages, or once the phase has
+// used NegotiationTokens tokens.
+func (g *Game) negotiate(order []int) {
+	if g.Rules.NegotiationRounds == 0 {
+		return
+	}
+
+	tools := g.negotiationToolDefinitions()
+	start := g.usage()
+
+	// Calls and messages made negotiating count towards the agent's coming turn, so they are
+	// summarised with it when it leaves the context window
+	for _, id := range order {
+		agent := &g.Agents[id]
+		if agent.Lost {
+			continue
+		}
+
+		agent.turnUsage = agent.Usage
+		agent.history = append(agent.history, turnMark{turn: agent.Turn + 1, index: len(agent.Prompt)})
+		agent.negotiated = true
+		agent.negotiationState = agent.state()
+	}
+
+	for round := 1; round <= g.Rules.NegotiationRounds; round++ {
+		sent := 0
+
+		for _, id := range order {
+			agent := &g.Agents[id]
+			if agent.Lost {
+				continue
+			}
+
+			if g.ended() {
+				return
+			}
+
+			used := g.usage().since(start)
+			if limit := g.Rules.NegotiationTokens; limit > 0 && used.PromptTokens+used.CompletionTokens >= limit {
+				fmt.Printf("Negotiation has used %d tokens, ending it\n", used.PromptTokens+used.CompletionTokens)
+				return
+			}
+
+			n, err := agent.Negotiate(g, round, tools)
+			if err != nil {
+				fmt.Printf("Agent %d could not negotiate: %v\n", agent.ID, err)
+				continue
+			}
+
+			sent += n
+		}
+
+		// Nobody had anything more to say
+		if sent == 0 {
+			return
+		}
+	}
+}
+
+// Negotiate asks the agent for the messages they want to send in a round of negotiation,
+// returning how many were sent. Messages don't use an action, but at most NegotiationMessages are
+// sent each round.
+func (a *Agent) Negotiate(g *Game, round int, tools []openai.Tool) (int, error) {
+	a.AddTurnLog(fmt.Sprintf("Before anyone acts in round %d, agents can negotiate. This is negotiation round %d of %d: you may send up to %d messages with the messaging tools, which don't use an action, and other agents can reply in the next negotiation round. Call end_negotiation if you have nothing to say. You will take your actions once negotiation is over.",
+		g.CurrentTurn+1, round, g.Rules.NegotiationRounds, g.Rules.NegotiationMessages))
+
+	request := g.chatRequest(a.Prompt)
+	request.Tools = tools
+
+	message, err := getToolCalls(g.ctx, a.llm, request)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get tool call: %w", err)
+	}
+
+	a.Prompt = append(a.Prompt, message)
+
+	names := []string{}
+	sent := 0
+	for _, toolCall := range message.ToolCalls {
+		names = append(names, toolCall.Function.Name)
+
+		var result ActionResult
+		switch {
+		case toolCall.Function.Name == "end_negotiation":
+			result = newActionResult(toolCall)
+			result.Success = true
+			result.Message = "Ended negotiation for this round"
+		case !negotiationTools[toolCall.Function.Name]:
+			result = invalidAction(toolCall, actionError(ErrUnknownAction, "%s cannot be used while negotiating, only messaging tools can", toolCall.Function.Name))
+		case sent >= g.Rules.NegotiationMessages:
+			result = newActionResult(toolCall)
+			result.setError(actionError(ErrNoActionsLeft, "Not sent, you have no messages left in this negotiation round"))
+		default:
+			result = a.TakeAction(g, toolCall)
+			if result.Success {
+				sent++
+			}
+		}
+
+		a.AddToolResult(toolCall.ID, result.content())
+		a.negotiation = append(a.negotiation, result)
+	}
+	fmt.Printf("Agent %d: negotiated with: %s\n", a.ID, strings.Join(names, ", "))
+
+	return sent, nil
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+// postOffer has every agent post an offer in the town square whenever they are offered tools
+func postOffer(int) []openai.FunctionCall {
+	return []openai.FunctionCall{{Name: "broadcast_message", Arguments: `{"message": "Wheat for gold?"}`}}
+}
+
+func withNegotiation(r *Ruleset) {
+	r.NumAgents = 3
+	r.NegotiationRounds = 3
+}
+
+// TestNegotiationBelongsToComingTurn checks that negotiation is recorded as part of the turn that
+// follows it, so it stays with that turn in the prompt and in the game log
+func TestNegotiationBelongsToComingTurn(t *testing.T) {
+	game := newTestGame(t, func(r *Ruleset) {
+		withNegotiation(r)
+		r.NegotiationRounds = 1
+	})
+	stubOf(game).actions = postOffer
+	agent, other := &game.Agents[0], &game.Agents[1]
+
+	game.negotiate([]int{0, 1, 2})
+	holdings := agent.state()
+
+	if len(agent.history) != 1 || agent.history[0].turn != 1 {
+		t.Fatalf("history after negotiating is %+v, want a mark for turn 1", agent.history)
+	}
+	mark := agent.history[0]
+
+	// Agent 1's offer reached Agent 0 after their turn's mark, labelled with the coming turn
+	if len(agent.notices) == 0 {
+		t.Fatal("Agent 0 wasn't told of the other agents' offers")
+	}
+	for _, index := range agent.notices {
+		if index < mark.index || !strings.HasPrefix(agent.Prompt[index].Content, "Turn 1: ") {
+			t.Errorf("notice %d before the turn's mark at %d: %q", index, mark.index, agent.Prompt[index].Content)
+		}
+	}
+
+	// Starting the turn keeps the mark made when negotiation began
+	if err := agent.StartTurn(game); err != nil {
+		t.Fatal(err)
+	}
+	if len(agent.history) != 1 || agent.history[0] != mark {
+		t.Errorf("history after starting the turn is %+v, want %+v", agent.history, mark)
+	}
+
+	turn := agent.newTurn()
+	if len(turn.Negotiation) != 1 || turn.Negotiation[0].Action != "broadcast_message" || !turn.Negotiation[0].Success {
+		t.Errorf("turn recorded negotiation %+v", turn.Negotiation)
+	}
+	if turn.NegotiationState == nil || turn.NegotiationState.Gold != holdings.Gold || turn.NegotiationState.Wheat != holdings.Wheat {
+		t.Errorf("turn recorded holdings %+v when negotiation began, want %+v", turn.NegotiationState, holdings)
+	}
+
+	// The next turn starts afresh
+	if next := agent.newTurn(); len(next.Negotiation) != 0 || next.NegotiationState != nil || agent.negotiated {
+		t.Errorf("negotiation carried over to the next turn: %+v", next.Negotiation)
+	}
+	if len(other.history) != 1 || other.history[0].turn != 1 {
+		t.Errorf("Agent 1's history is %+v", other.history)
+	}
+}
+
+func TestNegotiationSkipsLostAgents(t *testing.T) {
+	game := newTestGame(t, withNegotiation)
+	stub := stubOf(game)
+	stub.actions = postOffer
+
+	lost := &game.Agents[2]
+	lost.Lost = true
+	prompt := len(lost.Prompt)
+
+	game.negotiate([]int{0, 1, 2})
+
+	if len(lost.Prompt) != prompt || len(lost.history) != 0 || lost.negotiated {
+		t.Errorf("Agent 2 negotiated after losing")
+	}
+	if want := 2 * game.Rules.NegotiationRounds; stub.calls != want {
+		t.Errorf("%d calls were made, want %d", stub.calls, want)
+	}
+}
+
+func TestNegotiationTokenLimit(t *testing.T) {
+	// Each stubbed call uses 110 tokens, so the limit is passed after the second call
+	game := newTestGame(t, func(r *Ruleset) {
+		withNegotiation(r)
+		r.NegotiationTokens = 150
+	})
+	stub := stubOf(game)
+	stub.actions = postOffer
+
+	game.negotiate([]int{0, 1, 2})
+
+	if stub.calls != 2 {
+		t.Errorf("%d calls were made, want negotiation to end after 2", stub.calls)
+	}
+}
+
+func TestNegotiationEndsWhenQuiet(t *testing.T) {
+	game := newTestGame(t, withNegotiation)
+	stub := stubOf(game)
+	stub.actions = func(int) []openai.FunctionCall {
+		return []openai.FunctionCall{{Name: "end_negotiation", Arguments: `{}`}}
+	}
+
+	game.negotiate([]int{0, 1, 2})
+
+	if stub.calls != 3 {
+		t.Errorf("%d calls were made, want negotiation to end after a quiet round of 3", stub.calls)
+	}
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+// Note is an entry in an agent's private notebook
+type Note struct {
+	Turn int
+	Text string
+}
+
+// WriteNote adds a note to the agent's notebook, dropping the oldest once it is full
+func (a *Agent) WriteNote(g *Game, text string) error {
+	a.Notes = append(a.Notes, Note{Turn: a.Turn, Text: text})
+	a.AddTurnLog(fmt.Sprintf("Wrote a note: %s", text))
+
+	if size := g.Rules.NotebookSize; size > 0 && len(a.Notes) > size {
+		dropped := len(a.Notes) - size
+		a.Notes = a.Notes[dropped:]
+		a.AddTurnLog(fmt.Sprintf("Your notebook holds %d notes, so your oldest note was removed", size))
+	}
+
+	a.updateMemory()
+
+	return nil
+}
+
+// ReadNotes shows the agent everything in their notebook
+func (a *Agent) ReadNotes(g *Game) error {
+	if len(a.Notes) == 0 {
+		a.AddTurnLog("Your notebook is empty")
+		return nil
+	}
+
+	a.AddTurnLog("Your notebook:\n" + a.notebook())
+
+	return nil
+}
+
+// notebook lists the agent's notes, one per line
+func (a *Agent) notebook() string {
+	lines := make([]string, len(a.Notes))
+	for i, note := range a.Notes {
+		lines[i] = fmt.Sprintf("- Turn %d: %s", note.Turn, note.Text)
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// memory is what the agent should always remember, whatever is trimmed from their prompt: their
+// notebook and the summary of their older turns
+func (a *Agent) memory() string {
+	sections := []string{}
+
+	if len(a.Notes) > 0 {
+		sections = append(sections, "Your private notebook, which only you can see:\n"+a.notebook())
+	}
+
+	if a.summary != "" {
+		sections = append(sections, fmt.Sprintf("Summary of your turns up to turn %d, which have been removed from this conversation:\n%s", a.summarisedTo, a.summary))
+	}
+
+	return strings.Join(sections, "\n\n")
+}
+
+// headerLen is the number of messages at the start of the prompt that are never trimmed: the
+// system prompt, and the agent's memory once they have one
+func (a *Agent) headerLen() int {
+	if a.hasMemory {
+		return 2
+	}
+
+	return 1
+}
+
+// updateMemory refreshes the message after the system prompt that holds the agent's memory,
+// adding it the first time there is something to remember. The prompt is copied rather than
+// changed in place, as earlier turn records share it.
+func (a *Agent) updateMemory() {
+	content := a.memory()
+	if content == "" {
+		return
+	}
+
+	prompt := make([]openai.ChatCompletionMessage, 0, len(a.Prompt)+1)
+	prompt = append(prompt, a.Prompt[0], openai.ChatCompletionMessage{Role: "system", Content: content})
+	prompt = append(prompt, a.Prompt[a.headerLen():]...)
+
+	if !a.hasMemory {
+		for i := range a.history {
+			a.history[i].index++
+		}
+		for i := range a.notices {
+			a.notices[i]++
+		}
+		a.hasMemory = true
+	}
+
+	a.Prompt = prompt
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNotebookDropsOldestNotes(t *testing.T) {
+	game := newTestGame(t, func(r *Ruleset) { r.NotebookSize = 2 })
+	agent := &game.Agents[0]
+
+	for _, text := range []string{"first", "second", "third"} {
+		if err := agent.WriteNote(game, text); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if len(agent.Notes) != 2 || agent.Notes[0].Text != "second" || agent.Notes[1].Text != "third" {
+		t.Errorf("notebook holds %+v, want the last two notes", agent.Notes)
+	}
+}
+
+// TestFirstNoteShiftsIndexes checks that adding the memory message after the system prompt keeps
+// the agent's turns and notices pointing at the same messages
+func TestFirstNoteShiftsIndexes(t *testing.T) {
+	game := newTestGame(t, withCompressedSummaries)
+	agent, other := &game.Agents[0], &game.Agents[1]
+
+	agent.StartTurn(game)
+	if err := other.GiveResource(game, agent.ID, Resource{Type: Gold, Amount: 1}); err != nil {
+		t.Fatal(err)
+	}
+
+	turnStart := agent.Prompt[agent.history[0].index].Content
+	notice := agent.Prompt[agent.notices[0]].Content
+
+	if err := agent.WriteNote(game, "Agent 1 is generous"); err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(agent.Prompt[1].Content, "Agent 1 is generous") {
+		t.Errorf("memory message is %q", agent.Prompt[1].Content)
+	}
+	if got := agent.Prompt[agent.history[0].index].Content; got != turnStart {
+		t.Errorf("turn starts at %q, want %q", got, turnStart)
+	}
+	if got := agent.Prompt[agent.notices[0]].Content; got != notice {
+		t.Errorf("notice points at %q, want %q", got, notice)
+	}
+
+	// Summarising keeps the notebook alongside the summary
+	agent.StartTurn(game)
+	agent.summariseTurns(game, 1)
+
+	if memory := agent.Prompt[1].Content; !strings.Contains(memory, "Agent 1 is generous") || !strings.Contains(memory, "You have received 1 gold") {
+		t.Errorf("memory after summarising is %q", memory)
+	}
+}
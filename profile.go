@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+	"math"
+)
+
+// AgentProfile gives an agent its own starting conditions and skills. Profiles are assigned
+// to agents by position in the ruleset; agents without one play by the base rules.
+// Unset starting resources keep the ruleset's values, and multipliers of 0 are treated as 1.
+type AgentProfile struct {
+	Name        string
+	Description string
+
+	StartingGold    *int
+	StartingWheat   *int
+	StartingWorkers *int
+
+	WorkerCostMultiplier float64
+	FarmCostMultiplier   float64
+	MineCostMultiplier   float64
+
+	FarmProductionMultiplier float64
+	MineProductionMultiplier float64
+
+	ExtraActions int
+
+	// Persona names a persona overlay added to the agent's system prompt, such as
+	// "cooperative", "ruthless" or "honest", read from personas/<name>.tmpl
+	Persona string
+}
+
+// profileFor returns the profile assigned to an agent
+func (r Ruleset) profileFor(agentID int) AgentProfile {
+	if agentID < len(r.Profiles) {
+		return r.Profiles[agentID]
+	}
+
+	return AgentProfile{}
+}
+
+// Apply returns the rules as they apply to an agent with this profile
+func (p AgentProfile) Apply(rules Ruleset) Ruleset {
+	if p.StartingGold != nil {
+		rules.StartingGold = *p.StartingGold
+	}
+	if p.StartingWheat != nil {
+		rules.StartingWheat = *p.StartingWheat
+	}
+	if p.StartingWorkers != nil {
+		rules.StartingWorkers = *p.StartingWorkers
+	}
+
+	rules.WorkerCost = scale(rules.WorkerCost, p.WorkerCostMultiplier)
+	rules.FarmCost = scale(rules.FarmCost, p.FarmCostMultiplier)
+	rules.MineCost = scale(rules.MineCost, p.MineCostMultiplier)
+
+	rules.FarmProduction = scale(rules.FarmProduction, p.FarmProductionMultiplier)
+	rules.MineProduction = scale(rules.MineProduction, p.MineProductionMultiplier)
+
+	rules.ActionsPerTurn += p.ExtraActions
+
+	return rules
+}
+
+// Validate checks that the profile's values are usable
+func (p AgentProfile) Validate() error {
+	for _, start := range []*int{p.StartingGold, p.StartingWheat, p.StartingWorkers} {
+		if start != nil && *start < 0 {
+			return fmt.Errorf("starting resources cannot be negative")
+		}
+	}
+
+	multipliers := []float64{
+		p.WorkerCostMultiplier, p.FarmCostMultiplier, p.MineCostMultiplier,
+		p.FarmProductionMultiplier, p.MineProductionMultiplier,
+	}
+	for _, m := range multipliers {
+		if m < 0 {
+			return fmt.Errorf("multipliers cannot be negative")
+		}
+	}
+
+	if p.ExtraActions < 0 {
+		return fmt.Errorf("extra actions cannot be negative")
+	}
+
+	if !validPersona(p.Persona) {
+		return fmt.Errorf("persona %q must be a file name, without path separators or \"..\"", p.Persona)
+	}
+
+	return nil
+}
+
+func scale(value int, multiplier float64) int {
+	if multiplier == 0 {
+		return value
+	}
+
+	return int(math.Round(float64(value) * multiplier))
+}
+
+// rulesFor returns the game's current rules as they apply to an agent
+func (g *Game) rulesFor(agentID int) Ruleset {
+	return g.Agents[agentID].Profile.Apply(g.Rules)
+}
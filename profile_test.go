@@ -0,0 +1,106 @@
+package main
+
+import "testing"
+
+func TestProfileApply(t *testing.T) {
+	gold, workers := 200, 0
+	profile := AgentProfile{
+		Name:                     "Merchant",
+		StartingGold:             &gold,
+		StartingWorkers:          &workers,
+		WorkerCostMultiplier:     0.5,
+		MineCostMultiplier:       1.5,
+		FarmProductionMultiplier: 2,
+		ExtraActions:             1,
+	}
+
+	base := DefaultRuleset()
+	rules := profile.Apply(base)
+
+	tests := []struct {
+		field string
+		got   int
+		want  int
+	}{
+		{"StartingGold", rules.StartingGold, 200},
+		// Unset starting resources keep the ruleset's values
+		{"StartingWheat", rules.StartingWheat, base.StartingWheat},
+		// A starting value of 0 is set, not ignored
+		{"StartingWorkers", rules.StartingWorkers, 0},
+		{"WorkerCost", rules.WorkerCost, WorkerCost / 2},
+		// Multipliers of 0 are treated as 1
+		{"FarmCost", rules.FarmCost, FarmCost},
+		{"MineCost", rules.MineCost, MineCost * 3 / 2},
+		{"FarmProduction", rules.FarmProduction, FarmProduction * 2},
+		{"MineProduction", rules.MineProduction, MineProduction},
+		{"ActionsPerTurn", rules.ActionsPerTurn, ActionsPerTurn + 1},
+	}
+
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("%s is %d, want %d", tt.field, tt.got, tt.want)
+		}
+	}
+
+	if base.StartingGold == 200 || base.WorkerCost != WorkerCost {
+		t.Errorf("applying a profile changed the base ruleset")
+	}
+}
+
+func TestRulesFor(t *testing.T) {
+	gold := 300
+	game := newTestGame(t, func(r *Ruleset) {
+		r.NumAgents = 3
+		r.SeasonLength = 1
+		r.Profiles = []AgentProfile{
+			{Name: "Farmer", FarmProductionMultiplier: 2},
+			{Name: "Banker", StartingGold: &gold, MineCostMultiplier: 2},
+		}
+	})
+
+	if game.Agents[1].Gold != 300 || game.Agents[0].Gold != StartingGold || game.Agents[2].Gold != StartingGold {
+		t.Errorf("agents start with %d, %d and %d gold, want %d, 300 and %d",
+			game.Agents[0].Gold, game.Agents[1].Gold, game.Agents[2].Gold, StartingGold, StartingGold)
+	}
+
+	if cost := game.rulesFor(1).MineCost; cost != MineCost*2 {
+		t.Errorf("Agent 1's mines cost %d, want %d", cost, MineCost*2)
+	}
+
+	// Agents without a profile play by the base rules
+	if rules := game.rulesFor(2); rules.MineCost != MineCost || rules.FarmProduction != FarmProduction {
+		t.Errorf("Agent 2 has mine cost %d and farm production %d, want the base rules", rules.MineCost, rules.FarmProduction)
+	}
+
+	// Profile skills combine with the season: summer farms produce x1.5
+	game.CurrentTurn = 1
+	if got := game.farmProduction(0); got != FarmProduction*2*3/2 {
+		t.Errorf("Agent 0's farms produce %d in summer, want %d", got, FarmProduction*2*3/2)
+	}
+	if got := game.farmProduction(2); got != FarmProduction*3/2 {
+		t.Errorf("Agent 2's farms produce %d in summer, want %d", got, FarmProduction*3/2)
+	}
+}
+
+func TestValidateProfiles(t *testing.T) {
+	negative := -1
+
+	tests := []struct {
+		name     string
+		profiles []AgentProfile
+	}{
+		{"more profiles than agents", make([]AgentProfile, NumAgents+1)},
+		{"negative starting gold", []AgentProfile{{StartingGold: &negative}}},
+		{"negative multiplier", []AgentProfile{{FarmCostMultiplier: -1}}},
+		{"negative extra actions", []AgentProfile{{ExtraActions: -1}}},
+	}
+
+	for _, tt := range tests {
+		rules := DefaultRuleset()
+		rules.Profiles = tt.profiles
+
+		if err := rules.Validate(); err == nil {
+			t.Errorf("%s: ruleset was accepted", tt.name)
+		}
+	}
+}
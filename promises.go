@@ -0,0 +1,161 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+)
+
+// PromiseEvent is the event kind recorded when promises are kept or broken
+const PromiseEvent = "promise"
+
+// Promise statuses
+const (
+	PromisePending = "pending"
+	PromiseKept    = "kept"
+	PromiseBroken  = "broken"
+)
+
+// Promise is a commitment made with the make_promise tool to give another agent resources by the
+// end of a round. Transfers from the promiser to the promisee count towards it until it is kept or
+// its deadline passes.
+type Promise struct {
+	ID        int
+	Turn      int
+	FromAgent int
+	ToAgent   int
+	Resource  Resource
+	// Deadline is the last round the promise can be kept in, counted from 0 like CurrentTurn
+	Deadline  int
+	Delivered int
+	Status    string
+}
+
+// Reliability is how well an agent has kept their promises. Score is the share of their resolved
+// promises they kept, and is 0 until one has been resolved.
+type Reliability struct {
+	AgentID int
+	Kept    int
+	Broken  int
+	Pending int
+	Score   float64
+}
+
+// String describes the promise for agents and reports, with rounds counted from 1
+func (p Promise) String() string {
+	return fmt.Sprintf("Agent %d promised to give Agent %d %d %s by the end of round %d", p.FromAgent, p.ToAgent, p.Resource.Amount, p.Resource.Type, p.Deadline+1)
+}
+
+// MakePromise commits the agent to giving another agent resources by the end of a round, which
+// is tracked against their transfers. Promises are told to the recipient but nothing enforces them.
+func (a *Agent) MakePromise(g *Game, targetAgent int, resource Resource, deadlineRound int) error {
+	a.AddTurnLog(fmt.Sprintf("Attempting to promise Agent %d %d %s by the end of round %d", targetAgent, resource.Amount, resource.Type, deadlineRound))
+
+	if err := a.checkTarget(g, targetAgent); err != nil {
+		return fmt.Errorf("Failed to make promise: %w", err)
+	}
+
+	if deadlineRound-1 < g.CurrentTurn {
+		return actionError(ErrInvalidArguments, "Failed to make promise, round %d has already ended", deadlineRound)
+	}
+
+	promise := Promise{
+		ID:        len(g.Promises),
+		Turn:      g.CurrentTurn,
+		FromAgent: a.ID,
+		ToAgent:   targetAgent,
+		Resource:  resource,
+		Deadline:  deadlineRound - 1,
+		Status:    PromisePending,
+	}
+	g.Promises = append(g.Promises, promise)
+	g.unpushedPromises = append(g.unpushedPromises, promise)
+
+	a.AddTurnLog(fmt.Sprintf("You promised to give Agent %d %d %s by the end of round %d", targetAgent, resource.Amount, resource.Type, deadlineRound))
+	g.Agents[targetAgent].notify(fmt.Sprintf("Agent %d has promised to give you %d %s by the end of round %d", a.ID, resource.Amount, resource.Type, deadlineRound))
+
+	return nil
+}
+
+// deliverPromises counts a transfer towards the giver's pending promises to the recipient of that
+// resource, oldest first
+func (g *Game) deliverPromises(fromAgentID int, toAgentID int, resource Resource) {
+	amount := resource.Amount
+	for i := range g.Promises {
+		p := &g.Promises[i]
+		if amount == 0 {
+			return
+		}
+
+		if p.Status != PromisePending || p.FromAgent != fromAgentID || p.ToAgent != toAgentID || p.Resource.Type != resource.Type {
+			continue
+		}
+
+		delivered := min(amount, p.Resource.Amount-p.Delivered)
+		p.Delivered += delivered
+		amount -= delivered
+
+		if p.Delivered >= p.Resource.Amount {
+			p.Status = PromiseKept
+			g.unpushedPromises = append(g.unpushedPromises, *p)
+			g.recordEvent(PromiseEvent, p.FromAgent, p.ToAgent, true, fmt.Sprintf("%s, and kept their promise", p))
+		}
+	}
+}
+
+// checkPromises breaks every pending promise whose deadline is the round just played, telling
+// both agents
+func (g *Game) checkPromises() {
+	for i := range g.Promises {
+		p := &g.Promises[i]
+		if p.Status != PromisePending || p.Deadline > g.CurrentTurn {
+			continue
+		}
+
+		p.Status = PromiseBroken
+		g.unpushedPromises = append(g.unpushedPromises, *p)
+
+		msg := fmt.Sprintf("%s, but only gave %d: the promise is broken", p, p.Delivered)
+		g.Agents[p.FromAgent].notify(msg)
+		g.Agents[p.ToAgent].notify(msg)
+		g.recordEvent(PromiseEvent, p.FromAgent, p.ToAgent, false, msg)
+	}
+}
+
+// reliability scores how well every agent has kept their promises so far
+func (g *Game) reliability() []Reliability {
+	return reliabilityOf(g.Promises, len(g.Agents))
+}
+
+// reliabilityOf scores how well each of the agents kept the given promises
+func reliabilityOf(promises []Promise, numAgents int) []Reliability {
+	scores := make([]Reliability, numAgents)
+	for i := range scores {
+		scores[i].AgentID = i
+	}
+
+	for _, p := range promises {
+		switch p.Status {
+		case PromiseKept:
+			scores[p.FromAgent].Kept++
+		case PromiseBroken:
+			scores[p.FromAgent].Broken++
+		default:
+			scores[p.FromAgent].Pending++
+		}
+	}
+
+	for i := range scores {
+		if resolved := scores[i].Kept + scores[i].Broken; resolved > 0 {
+			scores[i].Score = float64(scores[i].Kept) / float64(resolved)
+		}
+	}
+
+	return scores
+}
+
+// brokenPromises returns every promise that has been broken
+func (g *Game) brokenPromises() []Promise {
+	return slices.DeleteFunc(slices.Clone(g.Promises), func(p Promise) bool {
+		return p.Status != PromiseBroken
+	})
+}
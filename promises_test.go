@@ -0,0 +1,168 @@
+package main
+
+import "testing"
+
+func withPromises(r *Ruleset) {
+	r.NumAgents = 3
+	r.StartingGold = 100
+	r.StartingWheat = 100
+}
+
+func promiseEvents(g *Game) []GameEvent {
+	events := []GameEvent{}
+	for _, event := range g.Events {
+		if event.Kind == PromiseEvent {
+			events = append(events, event)
+		}
+	}
+
+	return events
+}
+
+// TestPromiseKeptAcrossTransfers keeps a promise with two transfers, ignoring transfers of other
+// resources and to other agents
+func TestPromiseKeptAcrossTransfers(t *testing.T) {
+	g := newTestGame(t, withPromises)
+	giver := &g.Agents[0]
+
+	if err := giver.MakePromise(g, 1, Resource{Type: Gold, Amount: 10}, 2); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, transfer := range []struct {
+		target   int
+		resource Resource
+	}{
+		{1, Resource{Type: Wheat, Amount: 10}},
+		{2, Resource{Type: Gold, Amount: 10}},
+		{1, Resource{Type: Gold, Amount: 4}},
+	} {
+		if err := giver.GiveResource(g, transfer.target, transfer.resource); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if p := g.Promises[0]; p.Status != PromisePending || p.Delivered != 4 {
+		t.Fatalf("promise is %s with %d delivered, want pending with 4", p.Status, p.Delivered)
+	}
+
+	if err := giver.GiveResource(g, 1, Resource{Type: Gold, Amount: 6}); err != nil {
+		t.Fatal(err)
+	}
+
+	if p := g.Promises[0]; p.Status != PromiseKept || p.Delivered != 10 {
+		t.Fatalf("promise is %s with %d delivered, want kept with 10", p.Status, p.Delivered)
+	}
+
+	events := promiseEvents(g)
+	if len(events) != 1 || !events[0].Success {
+		t.Fatalf("recorded promise events %+v, want one kept promise", events)
+	}
+}
+
+// TestPromisesDeliveredOldestFirst splits a transfer across promises, filling the oldest first
+func TestPromisesDeliveredOldestFirst(t *testing.T) {
+	g := newTestGame(t, withPromises)
+	giver := &g.Agents[0]
+
+	for _, amount := range []int{5, 5} {
+		if err := giver.MakePromise(g, 1, Resource{Type: Wheat, Amount: amount}, 1); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := giver.GiveResource(g, 1, Resource{Type: Wheat, Amount: 7}); err != nil {
+		t.Fatal(err)
+	}
+
+	if p := g.Promises[0]; p.Status != PromiseKept || p.Delivered != 5 {
+		t.Errorf("first promise is %s with %d delivered, want kept with 5", p.Status, p.Delivered)
+	}
+	if p := g.Promises[1]; p.Status != PromisePending || p.Delivered != 2 {
+		t.Errorf("second promise is %s with %d delivered, want pending with 2", p.Status, p.Delivered)
+	}
+}
+
+func TestPromiseBrokenAtDeadline(t *testing.T) {
+	g := newTestGame(t, withPromises)
+
+	if err := g.Agents[0].MakePromise(g, 1, Resource{Type: Gold, Amount: 10}, 2); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := g.Agents[0].GiveResource(g, 1, Resource{Type: Gold, Amount: 3}); err != nil {
+		t.Fatal(err)
+	}
+
+	// The promise survives the end of the first round, and is broken at the end of the second
+	g.checkPromises()
+	if p := g.Promises[0]; p.Status != PromisePending {
+		t.Fatalf("promise is %s before its deadline, want pending", p.Status)
+	}
+
+	g.CurrentTurn = 1
+	g.checkPromises()
+	if p := g.Promises[0]; p.Status != PromiseBroken || p.Delivered != 3 {
+		t.Fatalf("promise is %s with %d delivered, want broken with 3", p.Status, p.Delivered)
+	}
+
+	events := promiseEvents(g)
+	if len(events) != 1 || events[0].Success {
+		t.Fatalf("recorded promise events %+v, want one broken promise", events)
+	}
+
+	// Transfers after the deadline don't count towards a broken promise
+	if err := g.Agents[0].GiveResource(g, 1, Resource{Type: Gold, Amount: 7}); err != nil {
+		t.Fatal(err)
+	}
+	if p := g.Promises[0]; p.Status != PromiseBroken || p.Delivered != 3 {
+		t.Errorf("broken promise became %s with %d delivered", p.Status, p.Delivered)
+	}
+
+	if broken := g.brokenPromises(); len(broken) != 1 {
+		t.Errorf("found %d broken promises, want 1", len(broken))
+	}
+}
+
+func TestMakePromiseRejectsPastDeadline(t *testing.T) {
+	g := newTestGame(t, withPromises)
+	g.CurrentTurn = 2
+
+	if err := g.Agents[0].MakePromise(g, 1, Resource{Type: Gold, Amount: 10}, 2); err == nil {
+		t.Fatal("accepted a promise for a round that has ended")
+	}
+
+	if err := g.Agents[0].MakePromise(g, 0, Resource{Type: Gold, Amount: 10}, 3); err == nil {
+		t.Fatal("accepted a promise to the agent themselves")
+	}
+
+	if len(g.Promises) != 0 {
+		t.Errorf("recorded %d promises, want none", len(g.Promises))
+	}
+}
+
+func TestReliability(t *testing.T) {
+	g := newTestGame(t, func(r *Ruleset) { r.NumAgents = 4 })
+	g.Promises = []Promise{
+		{FromAgent: 0, Status: PromiseKept},
+		{FromAgent: 0, Status: PromiseKept},
+		{FromAgent: 0, Status: PromiseBroken},
+		{FromAgent: 0, Status: PromisePending},
+		{FromAgent: 1, Status: PromiseBroken},
+		{FromAgent: 2, Status: PromisePending},
+	}
+
+	want := []Reliability{
+		{AgentID: 0, Kept: 2, Broken: 1, Pending: 1, Score: 2.0 / 3},
+		{AgentID: 1, Broken: 1},
+		{AgentID: 2, Pending: 1},
+		{AgentID: 3},
+	}
+
+	got := g.reliability()
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("Agent %d: got %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
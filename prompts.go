@@ -1,14 +1,33 @@
 package main
 
 import (
-	"log"
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
 	"strings"
 	"text/template"
 
 	openai "github.com/sashabaranov/go-openai"
 )
 
-var templateData = struct {
+// defaultPrompts are the templates used for any that a ruleset's PromptDir doesn't provide
+//
+//go:embed prompts
+var defaultPrompts embed.FS
+
+// Prompt template files, relative to the prompt directory
+const (
+	systemPromptFile = "system.tmpl"
+	turnPromptFile   = "turn.tmpl"
+	personaDir       = "personas"
+)
+
+type templateData struct {
 	WorkerCost        int
 	FarmCost          int
 	MineCost          int
@@ -23,100 +42,242 @@ var templateData = struct {
 	WinningGoldAmount int
 	MaxTurn           int
 	WheatPerWorker    int
-}{
-	WorkerCost:        WorkerCost,
-	FarmCost:          FarmCost,
-	MineCost:          MineCost,
-	StartingGold:      StartingGold,
-	StartingWheat:     StartingWheat,
-	StartingWorkers:   StartingWorkers,
-	StartingBuilding:  StartingBuilding,
-	ActionsPerTurn:    ActionsPerTurn,
-	FarmProduction:    FarmProduction,
-	MineProduction:    MineProduction,
-	WheatDecayRate:    WheatDecayRate,
-	WinningGoldAmount: WinningGoldAmount,
-	MaxTurn:           MaxTurns,
-	WheatPerWorker:    WheatPerWorker,
+	RaidGoldCost      int
+	RaidStealPercent  int
+	SabotageCost      int
+	SabotageDuration  int
+	WallCost          int
+	GuardCost         int
+	SeasonLength      int
+	Seasons           []Season
+
+	AllianceSharedVisibility bool
+	AllianceVictoryGold      int
+
+	Governance bool
+	TaxRate    float64
+
+	FreeCallsPerTurn int
+	Visibility       string
+
+	InformationModel        string
+	InformationDelay        int
+	InformationNoisePercent int
+
+	Profile     AgentProfile
+	HasProfiles bool
+
+	// AgentID and Rules let templates use any of the rules as they apply to the agent
+	AgentID int
+	Rules   Ruleset
 }
 
-func getSystemPrompt() string {
-
-	var systemPromptTemplate = `
-"You are an AI agent participating in a resource management and negotiation game called Aconomy. Your goal is to accumulate {{ .WinningGoldAmount }} gold before any other agent. Here are the key details of the game:
-
-1. Resources: There are two main resources - Gold and Wheat.
-2. Buildings: You can build Farms (produce wheat) and Mines (produce gold).
-3. Workers: You need workers to operate buildings. Each worker consumes {{ .WheatPerWorker }} wheat per turn, or two if they are working in a building.
-4. Starting conditions: You begin with {{ .StartingGold }} Gold, {{ .StartingWheat }} Wheat, {{ .StartingWorkers }} Workers, and {{ .StartingBuilding }} Buildings.
-5. Actions: Each turn, you can perform {{ .ActionsPerTurn }} actions from the following:
-   - Give resources (gold or wheat) to another agent
-   - Buy workers ({{ .WorkerCost }} gold each)
-   - Buy buildings (Farm: {{ .FarmCost }} gold, Mine: {{ .MineCost }} gold)
-   - Send a message to another agent
-   - End your turn early
-   - Man a building with a worker so that it produces resources (workers manning a building consume 2*{{ .WheatPerWorker }} wheat per turn)
-   - Unman a building so that it stops producing resources
-6. Production:
-   - A manned Farm produces {{ .FarmProduction }} wheat per turn
-   - A manned Mine produces {{ .MineProduction }} gold per turn
-7. Wheat decays at {{ .WheatDecayRate }}*totalWheat per turn
-8. If you can't feed your workers, they will starve
-9. The game ends when an agent reaches {{ .WinningGoldAmount }} gold or after {{ .MaxTurn }} turns
-
-Your task is to make strategic decisions to grow your economy, manage your resources, and negotiate with other agents. Remember:
-
-- Trades are based on trust; there's no mechanism to enforce agreements
-- You can communicate freely with other agents to negotiate deals
-- Balance short-term gains with long-term strategy
-- Monitor your wheat production to ensure you can feed your workers. Unfed workers will die instantly.
-- Consider the actions of other agents and adapt your strategy accordingly
-
-In each turn, you will receive the current game state and must first strategise about your plan, then you will be given a chance to choose your actions.
-
-Good luck, and may the best strategist win!
-`
-
-	var templ = template.Must(template.New("systemPrompt").Parse(systemPromptTemplate))
+// newTemplateData builds the prompt variables from the rules as they apply to one agent
+func newTemplateData(rules Ruleset) templateData {
+	data := templateData{
+		WorkerCost:        rules.WorkerCost,
+		FarmCost:          rules.FarmCost,
+		MineCost:          rules.MineCost,
+		StartingGold:      rules.StartingGold,
+		StartingWheat:     rules.StartingWheat,
+		StartingWorkers:   rules.StartingWorkers,
+		StartingBuilding:  rules.StartingBuilding,
+		ActionsPerTurn:    rules.ActionsPerTurn,
+		FarmProduction:    rules.FarmProduction,
+		MineProduction:    rules.MineProduction,
+		WheatDecayRate:    rules.WheatDecayRate,
+		WinningGoldAmount: rules.WinningGoldAmount,
+		MaxTurn:           rules.MaxTurns,
+		WheatPerWorker:    rules.WheatPerWorker,
+		RaidGoldCost:      rules.RaidGoldCost,
+		RaidStealPercent:  int(rules.RaidStealFraction * 100),
+		SabotageCost:      rules.SabotageCost,
+		SabotageDuration:  rules.SabotageDuration,
+		WallCost:          rules.WallCost,
+		GuardCost:         rules.GuardCost,
 
-	tempWriter := new(strings.Builder)
+		AllianceSharedVisibility: rules.AllianceSharedVisibility,
+		AllianceVictoryGold:      rules.AllianceVictoryGold,
+
+		Governance: rules.Governance,
+		TaxRate:    rules.TaxRate,
+
+		FreeCallsPerTurn: rules.FreeCallsPerTurn,
+		Visibility:       rules.Visibility,
 
-	err := templ.Execute(tempWriter, templateData)
+		InformationModel:        rules.InformationModel,
+		InformationDelay:        rules.InformationDelay,
+		InformationNoisePercent: int(rules.InformationNoise * 100),
+
+		Rules: rules,
+	}
+
+	if rules.SeasonsEnabled() {
+		data.SeasonLength = rules.SeasonLength
+		data.Seasons = rules.Seasons
+	}
+
+	return data
+}
+
+// PromptTemplates are the system and turn prompt templates for a game, and the persona overlays
+// its profiles use
+type PromptTemplates struct {
+	system   *template.Template
+	turn     *template.Template
+	personas map[string]*template.Template
+	// sources hold the text of every template, to tell template versions apart
+	sources map[string]string
+}
+
+// LoadPromptTemplates reads the prompt templates from the ruleset's PromptDir, falling back to the
+// defaults for any file it doesn't have
+func LoadPromptTemplates(rules Ruleset) (*PromptTemplates, error) {
+	p := &PromptTemplates{
+		personas: map[string]*template.Template{},
+		sources:  map[string]string{},
+	}
+
+	var err error
+	if p.system, err = p.load(rules.PromptDir, systemPromptFile); err != nil {
+		return nil, err
+	}
+
+	if p.turn, err = p.load(rules.PromptDir, turnPromptFile); err != nil {
+		return nil, err
+	}
+
+	for _, profile := range rules.Profiles {
+		if profile.Persona == "" || p.personas[profile.Persona] != nil {
+			continue
+		}
+
+		if !validPersona(profile.Persona) {
+			return nil, fmt.Errorf("persona %q must be a file name, without path separators or \"..\"", profile.Persona)
+		}
+
+		persona, err := p.load(rules.PromptDir, filepath.Join(personaDir, profile.Persona+".tmpl"))
+		if err != nil {
+			return nil, fmt.Errorf("persona %q: %w", profile.Persona, err)
+		}
+
+		p.personas[profile.Persona] = persona
+	}
+
+	return p, nil
+}
+
+// validPersona reports whether a persona name can only refer to a file in the personas directory
+func validPersona(name string) bool {
+	return !strings.ContainsAny(name, `/\`) && !strings.Contains(name, "..")
+}
+
+// check renders every template for each agent the ruleset describes, so templates that refer to
+// missing fields are caught before the game starts rather than during it
+func (p *PromptTemplates) check(rules Ruleset) error {
+	for i := 0; i < rules.NumAgents; i++ {
+		profile := rules.profileFor(i)
+		agentRules := profile.Apply(rules)
+
+		if _, err := p.SystemPrompt(agentRules, i, profile, len(rules.Profiles) > 0); err != nil {
+			return err
+		}
+
+		if _, err := p.TurnPrompt(agentRules, i); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// load reads and parses a template from the prompt directory, or from the defaults if the
+// directory doesn't have it
+func (p *PromptTemplates) load(dir string, name string) (*template.Template, error) {
+	var data []byte
+	err := fs.ErrNotExist
+
+	if dir != "" {
+		data, err = os.ReadFile(filepath.Join(dir, name))
+	}
+
+	if errors.Is(err, fs.ErrNotExist) {
+		data, err = defaultPrompts.ReadFile(filepath.ToSlash(filepath.Join("prompts", name)))
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to read prompt template %s: %w", name, err)
+	}
+
+	templ, err := template.New(name).Parse(string(data))
 	if err != nil {
-		log.Fatalf("Error executing template: %v", err)
+		return nil, fmt.Errorf("failed to parse prompt template %s: %w", name, err)
 	}
 
-	return tempWriter.String()
+	p.sources[name] = string(data)
+
+	return templ, nil
 }
 
-func getTurnPrompt() string {
-	var turnPromptTemplate = `
-   It is now your turn to take actions. Remember, you can perform any {{ .ActionsPerTurn }} actions from the following:
-- Give resources (gold or wheat) to another agent
-- Buy workers ({{ .WorkerCost }} gold each)
-- Buy buildings (Farm: {{ .FarmCost }} gold, Mine: {{ .MineCost }} gold)
-- Send a message to another agent
-- End your turn early
-- Man a building with a worker so that it produces resources
-- Unman a building so that it stops producing resources
-You can perform any combination of these actions, up to {{ .ActionsPerTurn }} total actions per turn. Please choose one action at a time. To choose an action, please return one of the provided Tool Calls.
-Please explain your reasoning for each action you take.
-`
-
-	var templ = template.Must(template.New("turnPrompt").Parse(turnPromptTemplate))
+// Version identifies the templates an agent with the given persona is prompted with, so turns
+// played with different prompts can be told apart
+func (p *PromptTemplates) Version(persona string) string {
+	h := sha256.New()
+	for _, name := range []string{systemPromptFile, turnPromptFile, filepath.Join(personaDir, persona+".tmpl")} {
+		fmt.Fprintf(h, "%s\x00%s\x00", name, p.sources[name])
+	}
+
+	return hex.EncodeToString(h.Sum(nil))[:12]
+}
 
+func render(templ *template.Template, data templateData) (string, error) {
 	tempWriter := new(strings.Builder)
 
-	err := templ.Execute(tempWriter, templateData)
+	err := templ.Execute(tempWriter, data)
 	if err != nil {
-		log.Fatalf("Error executing template: %v", err)
+		return "", fmt.Errorf("failed to render prompt template %s: %w", templ.Name(), err)
 	}
 
-	return tempWriter.String()
+	return tempWriter.String(), nil
 }
 
-func basePrompt() []openai.ChatCompletionMessage {
-	sysPrompt := getSystemPrompt()
+// SystemPrompt renders the system prompt for an agent, followed by their persona if they have one
+func (p *PromptTemplates) SystemPrompt(rules Ruleset, agentID int, profile AgentProfile, hasProfiles bool) (string, error) {
+	data := newTemplateData(rules)
+	data.Profile = profile
+	data.HasProfiles = hasProfiles
+	data.AgentID = agentID
+
+	prompt, err := render(p.system, data)
+	if err != nil {
+		return "", err
+	}
+
+	if persona, ok := p.personas[profile.Persona]; ok {
+		overlay, err := render(persona, data)
+		if err != nil {
+			return "", err
+		}
+
+		prompt += "\n" + overlay
+	}
+
+	return prompt, nil
+}
+
+// TurnPrompt renders the prompt that starts each of an agent's turns
+func (p *PromptTemplates) TurnPrompt(rules Ruleset, agentID int) (string, error) {
+	data := newTemplateData(rules)
+	data.AgentID = agentID
+
+	return render(p.turn, data)
+}
+
+func (p *PromptTemplates) basePrompt(rules Ruleset, agentID int, profile AgentProfile, hasProfiles bool) ([]openai.ChatCompletionMessage, error) {
+	sysPrompt, err := p.SystemPrompt(rules, agentID, profile, hasProfiles)
+	if err != nil {
+		return nil, err
+	}
 
 	// fmt.Printf("System prompt: %s\n", sysPrompt)
 	return []openai.ChatCompletionMessage{
@@ -124,5 +285,5 @@ func basePrompt() []openai.ChatCompletionMessage {
 			Role:    "system",
 			Content: sysPrompt,
 		},
-	}
+	}, nil
 }
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDefaultPromptsRender(t *testing.T) {
+	rules := DefaultRuleset()
+	rules.SeasonLength = 2
+	rules.Governance = true
+	rules.Profiles = []AgentProfile{{Name: "Raider", Persona: "ruthless"}}
+
+	if err := rules.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	prompts, err := LoadPromptTemplates(rules)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	prompt, err := prompts.SystemPrompt(rules, 0, rules.profileFor(0), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, want := range []string{"Your profile is \"Raider\"", "Your persona is ruthless", "Share the treasury's wheat", "Winter"} {
+		if !strings.Contains(prompt, want) {
+			t.Errorf("system prompt is missing %q", want)
+		}
+	}
+
+	if prompts.Version("ruthless") == prompts.Version("") {
+		t.Error("a persona doesn't change the prompt version")
+	}
+}
+
+func TestPromptDirOverridesDefaults(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, turnPromptFile), []byte("Your move, Agent {{ .AgentID }}"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	rules := DefaultRuleset()
+	defaults, err := LoadPromptTemplates(rules)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rules.PromptDir = dir
+	prompts, err := LoadPromptTemplates(rules)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if prompt, err := prompts.TurnPrompt(rules, 2); err != nil || prompt != "Your move, Agent 2" {
+		t.Errorf("turn prompt is %q, %v", prompt, err)
+	}
+
+	// The system prompt isn't in the directory, so the default is used
+	system, _ := prompts.SystemPrompt(rules, 0, AgentProfile{}, false)
+	defaultSystem, _ := defaults.SystemPrompt(rules, 0, AgentProfile{}, false)
+	if system != defaultSystem {
+		t.Error("system prompt differs from the default")
+	}
+
+	if prompts.Version("") == defaults.Version("") {
+		t.Error("overriding the turn prompt doesn't change the prompt version")
+	}
+}
+
+func TestValidateChecksPrompts(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, turnPromptFile), []byte("{{ .NoSuchField }}"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	rules := DefaultRuleset()
+	rules.PromptDir = dir
+	if err := rules.Validate(); err == nil {
+		t.Error("a template with a missing field was accepted")
+	}
+
+	rules = DefaultRuleset()
+	rules.Profiles = []AgentProfile{{Persona: "../system"}}
+	if err := rules.Validate(); err == nil {
+		t.Error("a persona outside the personas directory was accepted")
+	}
+
+	rules.Profiles = []AgentProfile{{Persona: "nobody"}}
+	if err := rules.Validate(); err == nil {
+		t.Error("a missing persona was accepted")
+	}
+}
@@ -0,0 +1,279 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// Visibility settings
+const (
+	// FullVisibility lets agents query everything about every other agent
+	FullVisibility = "full"
+	// PartialVisibility shows rough figures for other agents' gold and wheat, and how many
+	// buildings they have but not whether they are manned
+	PartialVisibility = "partial"
+	// FogOfWar hides other agents' holdings entirely, except from allies who share visibility
+	FogOfWar = "fog"
+)
+
+// Prices are the costs and production an agent faced at the start of a round
+type Prices struct {
+	Turn           int
+	AgentID        int
+	WorkerCost     int
+	FarmCost       int
+	MineCost       int
+	WallCost       int
+	GuardCost      int
+	RaidGoldCost   int
+	SabotageCost   int
+	FarmProduction int
+	MineProduction int
+	WheatDecayRate float64
+	TaxRate        float64
+}
+
+// recordPrices adds every remaining agent's prices for the round to the price history
+func (g *Game) recordPrices() {
+	for _, agent := range g.Agents {
+		if agent.Lost {
+			continue
+		}
+
+		rules := g.rulesFor(agent.ID)
+		g.PriceHistory = append(g.PriceHistory, Prices{
+			Turn:           g.CurrentTurn,
+			AgentID:        agent.ID,
+			WorkerCost:     rules.WorkerCost,
+			FarmCost:       rules.FarmCost,
+			MineCost:       rules.MineCost,
+			WallCost:       rules.WallCost,
+			GuardCost:      rules.GuardCost,
+			RaidGoldCost:   rules.RaidGoldCost,
+			SabotageCost:   rules.SabotageCost,
+			FarmProduction: g.farmProduction(agent.ID),
+			MineProduction: g.mineProduction(agent.ID),
+			WheatDecayRate: g.wheatDecayRate(),
+			TaxRate:        g.Rules.TaxRate,
+		})
+	}
+}
+
+// visibilityOf returns how much the agent can see of another agent. Allies who share visibility
+// always see each other in full, and hidden information hides everyone else as fog of war does.
+func (g *Game) visibilityOf(agentID int, targetID int) string {
+	if g.seesExactly(agentID, targetID) {
+		return FullVisibility
+	}
+
+	if g.Rules.InformationModel == HiddenInformation {
+		return FogOfWar
+	}
+
+	return g.Rules.Visibility
+}
+
+// InspectAgent shows the agent what they can see of another agent
+func (a *Agent) InspectAgent(g *Game, targetAgent int) error {
+	state, round, _ := g.observe(a.ID, targetAgent)
+
+	switch g.visibilityOf(a.ID, targetAgent) {
+	case FullVisibility:
+		a.AddTurnLog(fmt.Sprintf(
+			"Agent %d%s: Gold: %d, Wheat: %d, Workers: %d (%d unoccupied), Walls: %d, Guards: %d\nBuildings: %s\n%s",
+			targetAgent, g.observationNote(a.ID, targetAgent, round), state.Gold, state.Wheat, state.Workers,
+			state.Workers-mannedBuildings(state.Buildings), state.Walls, state.Guards, describeBuildings(state.Buildings),
+			g.describeAlliance(targetAgent),
+		))
+	case PartialVisibility:
+		a.AddTurnLog(fmt.Sprintf(
+			"Agent %d%s: about %d gold, about %d wheat, Workers: %d, Walls: %d, Guards: %d\nBuildings: %s\n%s",
+			targetAgent, g.observationNote(a.ID, targetAgent, round), roughly(state.Gold), roughly(state.Wheat), state.Workers,
+			state.Walls, state.Guards, countBuildings(state.Buildings), g.describeAlliance(targetAgent),
+		))
+	default:
+		a.AddTurnLog(fmt.Sprintf("Agent %d is still in the game, but you cannot see their holdings", targetAgent))
+	}
+
+	return nil
+}
+
+// ListBuildings shows the agent what they can see of every agent's buildings
+func (a *Agent) ListBuildings(g *Game) error {
+	lines := []string{}
+	for i := range g.Agents {
+		agent := &g.Agents[i]
+		name := fmt.Sprintf("Agent %d", agent.ID)
+		if agent.ID == a.ID {
+			name += " (you)"
+		}
+
+		if agent.Lost {
+			lines = append(lines, name+": eliminated")
+			continue
+		}
+
+		state, round, _ := g.observe(a.ID, agent.ID)
+		if g.Rules.InformationModel == DelayedInformation {
+			name += g.observationNote(a.ID, agent.ID, round)
+		}
+
+		switch g.visibilityOf(a.ID, agent.ID) {
+		case FullVisibility:
+			lines = append(lines, fmt.Sprintf("%s: %s", name, describeBuildings(state.Buildings)))
+		case PartialVisibility:
+			lines = append(lines, fmt.Sprintf("%s: %s", name, countBuildings(state.Buildings)))
+		default:
+			lines = append(lines, name+": hidden")
+		}
+	}
+
+	a.AddTurnLog("Buildings:\n" + strings.Join(lines, "\n"))
+
+	return nil
+}
+
+// ViewPriceHistory shows the agent the costs and production they have faced each round, most
+// recent last. Only the last turns rounds are shown when it is greater than 0.
+func (a *Agent) ViewPriceHistory(g *Game, turns int) error {
+	lines := []string{}
+	for _, prices := range g.PriceHistory {
+		if prices.AgentID != a.ID {
+			continue
+		}
+
+		line := fmt.Sprintf(
+			"Round %d: Worker %d, Farm %d, Mine %d, Wall %d, Guard %d, Raid %d, Sabotage %d gold; Farms produce %d wheat, Mines produce %d gold; wheat decays at %.2f",
+			prices.Turn+1, prices.WorkerCost, prices.FarmCost, prices.MineCost, prices.WallCost, prices.GuardCost,
+			prices.RaidGoldCost, prices.SabotageCost, prices.FarmProduction, prices.MineProduction, prices.WheatDecayRate,
+		)
+		if g.Rules.Governance {
+			line += fmt.Sprintf(", tax rate %.2f", prices.TaxRate)
+		}
+
+		lines = append(lines, line)
+	}
+
+	if turns > 0 && len(lines) > turns {
+		lines = lines[len(lines)-turns:]
+	}
+
+	a.AddTurnLog("Price history:\n" + strings.Join(lines, "\n"))
+
+	return nil
+}
+
+// ViewLedger shows the agent how their holdings changed and what they did in each of their
+// completed turns, with the raids and sabotage they suffered. Only the last turns turns are shown
+// when it is greater than 0.
+func (a *Agent) ViewLedger(g *Game, turns int) error {
+	lines := []string{}
+	for _, turn := range g.GameLog {
+		if turn.AgentID != a.ID {
+			continue
+		}
+
+		line := fmt.Sprintf("Turn %d: Gold %d -> %d, Wheat %d -> %d, Workers %d -> %d, Buildings %d -> %d.",
+			turn.Turn, turn.StartState.Gold, turn.EndState.Gold, turn.StartState.Wheat, turn.EndState.Wheat,
+			turn.StartState.Workers, turn.EndState.Workers, len(turn.StartState.Buildings), len(turn.EndState.Buildings))
+
+		actions := []string{}
+		for _, action := range turn.Actions {
+			if !freeTools[action.Action] {
+				actions = append(actions, compressAction(action))
+			}
+		}
+		if len(actions) > 0 {
+			line += " Actions: " + strings.Join(actions, ", ") + "."
+		}
+
+		if turn.Forfeited {
+			line += " Forfeited."
+		}
+
+		lines = append(lines, line)
+	}
+
+	if turns > 0 && len(lines) > turns {
+		lines = lines[len(lines)-turns:]
+	}
+
+	if len(lines) == 0 {
+		lines = append(lines, "No completed turns yet")
+	}
+
+	for _, event := range g.Events {
+		if event.TargetID == a.ID && event.AgentID != a.ID {
+			lines = append(lines, fmt.Sprintf("Round %d: %s", event.Turn+1, event.Message))
+		}
+	}
+
+	a.AddTurnLog("Your ledger:\n" + strings.Join(lines, "\n"))
+
+	return nil
+}
+
+// describeAlliance says which alliance, if any, an agent is in
+func (g *Game) describeAlliance(agentID int) string {
+	alliance := g.allianceOf(agentID)
+	if alliance == nil {
+		return "Alliance: none"
+	}
+
+	return fmt.Sprintf("Alliance: alliance %d with Agents %s", alliance.ID, joinAgentIDs(alliance.Members))
+}
+
+// describeBuildings lists buildings with whether they are manned or disabled
+func describeBuildings(buildings []Building) string {
+	if len(buildings) == 0 {
+		return "none"
+	}
+
+	parts := make([]string, len(buildings))
+	for i, building := range buildings {
+		manned := "unmanned"
+		if building.Manned {
+			manned = "manned"
+		}
+
+		parts[i] = fmt.Sprintf("%s (%s", building.Type, manned)
+		if building.DisabledTurns > 0 {
+			parts[i] += fmt.Sprintf(", disabled for %d more turns", building.DisabledTurns)
+		}
+		parts[i] += ")"
+	}
+
+	return strings.Join(parts, ", ")
+}
+
+// mannedBuildings counts the buildings with a worker in them
+func mannedBuildings(buildings []Building) int {
+	manned := 0
+	for _, building := range buildings {
+		if building.Manned {
+			manned++
+		}
+	}
+
+	return manned
+}
+
+// countBuildings says how many of each type of building there are
+func countBuildings(buildings []Building) string {
+	farms, mines := 0, 0
+	for _, building := range buildings {
+		if building.Type == Farm {
+			farms++
+		} else if building.Type == Mine {
+			mines++
+		}
+	}
+
+	return fmt.Sprintf("%d Farms, %d Mines", farms, mines)
+}
+
+// roughly rounds a figure to the nearest ten
+func roughly(n int) int {
+	return int(math.Round(float64(n)/10)) * 10
+}
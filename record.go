@@ -0,0 +1,213 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+// LLMExchange is one call to the model made on behalf of an agent. Calls that failed, even after
+// retrying, are recorded with their error so the resulting forfeits replay too.
+type LLMExchange struct {
+	RequestHash string
+	Response    openai.ChatCompletionResponse
+	Error       string `json:",omitempty"`
+}
+
+// GameRecord is everything needed to review a finished game or replay it exactly
+type GameRecord struct {
+	Seed        int64
+	Rules       Ruleset
+	GameLog     GameLog
+	Events      []GameEvent
+	Channels    []Channel
+	Promises    []Promise
+	Deception   DeceptionReport
+	Outcome     Outcome
+	Transcripts [][]LLMExchange
+}
+
+// MarshalJSON writes the turn's error as its message, which the UI shows and records can read back
+func (t AgentTurn) MarshalJSON() ([]byte, error) {
+	type agentTurn AgentTurn
+
+	var message *string
+	if t.Error != nil {
+		text := t.Error.Error()
+		message = &text
+	}
+
+	return json.Marshal(struct {
+		agentTurn
+		Error *string
+	}{agentTurn(t), message})
+}
+
+// UnmarshalJSON reads a turn written by MarshalJSON
+func (t *AgentTurn) UnmarshalJSON(data []byte) error {
+	type agentTurn AgentTurn
+
+	turn := struct {
+		*agentTurn
+		Error *string
+	}{agentTurn: (*agentTurn)(t)}
+
+	if err := json.Unmarshal(data, &turn); err != nil {
+		return err
+	}
+
+	t.Error = nil
+	if turn.Error != nil {
+		t.Error = errors.New(*turn.Error)
+	}
+
+	return nil
+}
+
+// Record captures the game so far
+func (g *Game) Record() GameRecord {
+	rules := g.startRules
+	rules.Seed = g.Seed
+
+	return GameRecord{
+		Seed:        g.Seed,
+		Rules:       rules,
+		GameLog:     g.GameLog,
+		Events:      g.Events,
+		Channels:    g.Channels,
+		Promises:    g.Promises,
+		Deception:   AnalyseDeception(g.GameLog, rules),
+		Outcome:     g.outcome(),
+		Transcripts: g.Transcripts,
+	}
+}
+
+// SaveRecord writes the game record to disk as JSON
+func (g *Game) SaveRecord(path string) error {
+	data, err := json.MarshalIndent(g.Record(), "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal game record: %w", err)
+	}
+
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write game record: %w", err)
+	}
+
+	return nil
+}
+
+// LoadRecord reads a game record written by SaveRecord
+func LoadRecord(path string) (GameRecord, error) {
+	// Rules missing from older records keep their default values
+	record := GameRecord{Rules: DefaultRuleset()}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return record, fmt.Errorf("failed to read game record: %w", err)
+	}
+
+	if err := json.Unmarshal(data, &record); err != nil {
+		return record, fmt.Errorf("failed to parse game record: %w", err)
+	}
+
+	return record, nil
+}
+
+// Replay makes every agent answer with the model responses from a recorded game instead of calling
+// the model. The game must have been created with the record's rules, which carry its seed.
+func (g *Game) Replay(record GameRecord) error {
+	if len(record.Transcripts) != len(g.Agents) {
+		return fmt.Errorf("record has transcripts for %d agents, game has %d", len(record.Transcripts), len(g.Agents))
+	}
+
+	for i := range g.Agents {
+		g.Agents[i].llm = &recordingClient{
+			client:     &replayClient{agentID: i, exchanges: record.Transcripts[i]},
+			transcript: &g.Transcripts[i],
+			usage:      &g.Agents[i].Usage,
+		}
+	}
+
+	return nil
+}
+
+// hashRequest identifies a completion request by its content
+func hashRequest(request openai.ChatCompletionRequest) (string, error) {
+	data, err := json.Marshal(request)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	sum := sha256.Sum256(data)
+
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// recordingClient keeps a transcript of one agent's calls to the model
+type recordingClient struct {
+	client     ChatClient
+	transcript *[]LLMExchange
+	// usage counts the tokens and cost of the agent's calls
+	usage *Usage
+}
+
+func (c *recordingClient) CreateChatCompletion(ctx context.Context, request openai.ChatCompletionRequest) (openai.ChatCompletionResponse, error) {
+	hash, err := hashRequest(request)
+	if err != nil {
+		return openai.ChatCompletionResponse{}, err
+	}
+
+	resp, err := c.client.CreateChatCompletion(ctx, request)
+	if err != nil {
+		*c.transcript = append(*c.transcript, LLMExchange{RequestHash: hash, Error: err.Error()})
+		return resp, err
+	}
+
+	*c.transcript = append(*c.transcript, LLMExchange{RequestHash: hash, Response: resp})
+
+	// The fallback model may have answered instead of the one requested
+	model := resp.Model
+	if model == "" {
+		model = request.Model
+	}
+	c.usage.add(model, resp.Usage)
+
+	return resp, nil
+}
+
+// replayClient answers one agent's calls with the responses from a recorded transcript, in order
+type replayClient struct {
+	agentID   int
+	exchanges []LLMExchange
+	next      int
+}
+
+func (c *replayClient) CreateChatCompletion(_ context.Context, request openai.ChatCompletionRequest) (openai.ChatCompletionResponse, error) {
+	if c.next >= len(c.exchanges) {
+		return openai.ChatCompletionResponse{}, fmt.Errorf("replay of Agent %d ran out of recorded responses after %d calls", c.agentID, c.next)
+	}
+
+	hash, err := hashRequest(request)
+	if err != nil {
+		return openai.ChatCompletionResponse{}, err
+	}
+
+	exchange := c.exchanges[c.next]
+	if exchange.RequestHash != hash {
+		return openai.ChatCompletionResponse{}, fmt.Errorf("replay of Agent %d diverged from the recording at call %d", c.agentID, c.next)
+	}
+
+	c.next++
+
+	if exchange.Error != "" {
+		return openai.ChatCompletionResponse{}, errors.New(exchange.Error)
+	}
+
+	return exchange.Response, nil
+}
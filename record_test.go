@@ -0,0 +1,95 @@
+package main
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"testing"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+// raidAgentZero has every agent raid Agent 0 on every other call, and buy a worker in between
+func raidAgentZero(call int) []openai.FunctionCall {
+	if call%2 == 0 {
+		return []openai.FunctionCall{{Name: "buy_worker", Arguments: `{"count": 1}`}}
+	}
+
+	return []openai.FunctionCall{{Name: "raid", Arguments: `{"target_agent": 0, "workers": 1}`}}
+}
+
+// TestReplayReproducesGame replays a saved game without the model, which must play out the same
+func TestReplayReproducesGame(t *testing.T) {
+	for _, mode := range []string{SequentialTurns, SimultaneousTurns} {
+		t.Run(mode, func(t *testing.T) {
+			rules := DefaultRuleset()
+			rules.TurnMode = mode
+			rules.MaxTurns = 4
+			rules.StartingWorkers = 3
+			rules.Seed = 3
+
+			game, err := NewGame(nil, &stubClient{actions: raidAgentZero}, rules)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			RunGame(game)
+
+			raids := 0
+			for _, event := range game.Events {
+				if event.Kind == RaidEvent {
+					raids++
+				}
+			}
+			if raids == 0 {
+				t.Fatal("no raids were made, so the seed was never used")
+			}
+
+			path := filepath.Join(t.TempDir(), "game.json")
+			if err := game.SaveRecord(path); err != nil {
+				t.Fatal(err)
+			}
+
+			record, err := LoadRecord(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			replay, err := NewGame(nil, &stubClient{}, record.Rules)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if err := replay.Replay(record); err != nil {
+				t.Fatal(err)
+			}
+			RunGame(replay)
+
+			for _, turn := range replay.GameLog {
+				if turn.Error != nil {
+					t.Fatalf("Agent %d's replayed turn %d failed: %v", turn.AgentID, turn.Turn, turn.Error)
+				}
+			}
+
+			assertSameJSON(t, "game log", game.GameLog, replay.GameLog)
+			assertSameJSON(t, "events", game.Events, replay.Events)
+		})
+	}
+}
+
+func assertSameJSON(t *testing.T, name string, want any, got any) {
+	t.Helper()
+
+	wantJSON, err := json.Marshal(want)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gotJSON, err := json.Marshal(got)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(wantJSON) != string(gotJSON) {
+		t.Errorf("replayed %s differs from the original game", name)
+	}
+}
@@ -0,0 +1,556 @@
+package main
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"math"
+	"os"
+	"slices"
+	"strings"
+)
+
+// Report summarises a finished game for people who weren't watching it
+type Report struct {
+	Seed    int64
+	Outcome Outcome
+	Agents  []AgentReport
+	// Transfers are the resources given between each pair of agents over the game
+	Transfers     []Transfer
+	TurningPoints []TurningPoint
+	Lies          []Lie
+	Usage         Usage
+}
+
+// AgentReport is how one agent's game went
+type AgentReport struct {
+	ID     int
+	Winner bool
+	Final  State
+	// Curve is the agent's holdings at the start of the game and after each of their turns
+	Curve []Holdings
+	// Buildings are the rounds the agent's farms or mines changed in, and what they had after
+	Buildings   []BuildingChange
+	Messages    int
+	Lies        int
+	Reliability Reliability
+	Usage       Usage
+}
+
+// Holdings are an agent's gold, wheat and workers at the end of a round
+type Holdings struct {
+	Round   int
+	Gold    int
+	Wheat   int
+	Workers int
+}
+
+// BuildingChange is an agent's farms and mines after they changed
+type BuildingChange struct {
+	Round int
+	Farms int
+	Mines int
+}
+
+// Transfer is everything one agent gave another
+type Transfer struct {
+	From  int
+	To    int
+	Gold  int
+	Wheat int
+	Count int
+}
+
+// TurningPoint is something that changed the course of the game
+type TurningPoint struct {
+	Round   int
+	Message string
+}
+
+// BuildReport reads a game record into a report
+func BuildReport(record GameRecord) Report {
+	numAgents := record.Rules.NumAgents
+	for _, turn := range record.GameLog {
+		numAgents = max(numAgents, turn.AgentID+1)
+	}
+
+	outcome := record.Outcome
+	if outcome.Reason == "" {
+		outcome.Reason = "The record does not say how the game ended"
+	}
+
+	deception := AnalyseDeception(record.GameLog, record.Rules)
+	reliability := reliabilityOf(record.Promises, numAgents)
+
+	report := Report{
+		Seed:          record.Seed,
+		Outcome:       outcome,
+		Agents:        make([]AgentReport, numAgents),
+		Transfers:     transfers(record.GameLog),
+		TurningPoints: turningPoints(record),
+		Lies:          deception.Lies,
+	}
+
+	for i := range report.Agents {
+		// Turns start after feeding and production, so the game starts from the rules instead
+		rules := record.Rules.profileFor(i).Apply(record.Rules)
+		start := State{Gold: rules.StartingGold, Wheat: rules.StartingWheat, Workers: rules.StartingWorkers}
+
+		report.Agents[i] = AgentReport{
+			ID:          i,
+			Winner:      slices.Contains(outcome.Winners, i),
+			Final:       start,
+			Curve:       []Holdings{holdings(0, start)},
+			Buildings:   []BuildingChange{buildingChange(0, start)},
+			Reliability: reliability[i],
+		}
+
+		if i < len(deception.Agents) {
+			report.Agents[i].Messages = deception.Agents[i].Messages
+			report.Agents[i].Lies = deception.Agents[i].Lies
+		}
+	}
+
+	for _, turn := range record.GameLog {
+		if turn.AgentID < 0 || turn.AgentID >= numAgents {
+			continue
+		}
+
+		agent := &report.Agents[turn.AgentID]
+		agent.Final = turn.EndState
+		agent.Usage = turn.AgentUsage
+		agent.Curve = append(agent.Curve, holdings(turn.Turn, turn.EndState))
+
+		if change := buildingChange(turn.Turn, turn.EndState); change.Farms != agent.Buildings[len(agent.Buildings)-1].Farms || change.Mines != agent.Buildings[len(agent.Buildings)-1].Mines {
+			agent.Buildings = append(agent.Buildings, change)
+		}
+
+		// The latest turn knows the whole game's usage so far
+		if turn.GameUsage.Calls >= report.Usage.Calls {
+			report.Usage = turn.GameUsage
+		}
+	}
+
+	return report
+}
+
+// holdings reads an agent's gold, wheat and workers from their state
+func holdings(round int, state State) Holdings {
+	return Holdings{Round: round, Gold: state.Gold, Wheat: state.Wheat, Workers: state.Workers}
+}
+
+// buildingChange counts an agent's farms and mines
+func buildingChange(round int, state State) BuildingChange {
+	change := BuildingChange{Round: round}
+	for _, building := range state.Buildings {
+		switch building.Type {
+		case Farm:
+			change.Farms++
+		case Mine:
+			change.Mines++
+		}
+	}
+
+	return change
+}
+
+// transfers totals the resources given between each pair of agents, in the order they first gave
+func transfers(log GameLog) []Transfer {
+	totals := []Transfer{}
+	for _, turn := range log {
+		for _, result := range turn.Actions {
+			if !result.Success || result.Action != "give_resources" {
+				continue
+			}
+
+			var args giveResourcesArgs
+			if err := json.Unmarshal([]byte(result.Arguments), &args); err != nil {
+				continue
+			}
+
+			i := slices.IndexFunc(totals, func(t Transfer) bool {
+				return t.From == turn.AgentID && t.To == args.TargetAgent
+			})
+			if i < 0 {
+				totals = append(totals, Transfer{From: turn.AgentID, To: args.TargetAgent})
+				i = len(totals) - 1
+			}
+
+			totals[i].Count++
+			switch args.Resource.Type {
+			case Gold:
+				totals[i].Gold += args.Resource.Amount
+			case Wheat:
+				totals[i].Wheat += args.Resource.Amount
+			}
+		}
+	}
+
+	return totals
+}
+
+// turningPoints picks out the moments that changed the game: changes of the gold lead,
+// eliminations, successful raids and sabotage, alliances, passed policies and broken promises
+func turningPoints(record GameRecord) []TurningPoint {
+	points := []TurningPoint{}
+
+	gold := map[int]int{}
+	leader := -1
+	eliminated := map[int]bool{}
+	for _, turn := range record.GameLog {
+		gold[turn.AgentID] = turn.EndState.Gold
+
+		if next := goldLeader(gold); next >= 0 && next != leader {
+			if leader >= 0 {
+				points = append(points, TurningPoint{Round: turn.Turn, Message: fmt.Sprintf("Agent %d took the gold lead from Agent %d with %d gold", next, leader, gold[next])})
+			}
+			leader = next
+		}
+
+		state := turn.EndState
+		if state.Gold == 0 && state.Wheat == 0 && state.Workers == 0 && !eliminated[turn.AgentID] {
+			eliminated[turn.AgentID] = true
+			points = append(points, TurningPoint{Round: turn.Turn, Message: fmt.Sprintf("Agent %d was eliminated", turn.AgentID)})
+		}
+	}
+
+	for _, event := range record.Events {
+		switch {
+		case event.Kind == RaidEvent && event.Success,
+			event.Kind == SabotageEvent && event.Success,
+			event.Kind == AllianceEvent,
+			event.Kind == PolicyEvent && event.Success,
+			event.Kind == PromiseEvent && !event.Success:
+			points = append(points, TurningPoint{Round: event.Turn + 1, Message: event.Message})
+		}
+	}
+
+	slices.SortStableFunc(points, func(a, b TurningPoint) int {
+		return a.Round - b.Round
+	})
+
+	return points
+}
+
+// goldLeader returns the agent with the most gold, or -1 if the most is shared
+func goldLeader(gold map[int]int) int {
+	leader, most, shared := -1, -1, false
+	for agentID, amount := range gold {
+		switch {
+		case amount > most:
+			leader, most, shared = agentID, amount, false
+		case amount == most:
+			shared = true
+		}
+	}
+
+	if shared {
+		return -1
+	}
+
+	return leader
+}
+
+// winners describes who won
+func (r Report) winners() string {
+	if len(r.Outcome.Winners) == 0 {
+		return "Nobody"
+	}
+
+	if len(r.Outcome.Winners) == 1 {
+		return fmt.Sprintf("Agent %d", r.Outcome.Winners[0])
+	}
+
+	return "Agents " + joinAgentIDs(r.Outcome.Winners)
+}
+
+// sparkline draws values as a line of block characters scaled to the largest
+func sparkline(values []int) string {
+	const blocks = "▁▂▃▄▅▆▇█"
+	bars := []rune(blocks)
+
+	top := 0
+	for _, v := range values {
+		top = max(top, v)
+	}
+
+	var b strings.Builder
+	for _, v := range values {
+		i := 0
+		if top > 0 {
+			i = v * (len(bars) - 1) / top
+		}
+		b.WriteRune(bars[max(i, 0)])
+	}
+
+	return b.String()
+}
+
+// curve picks one of the holdings out of an agent's curve
+func curve(points []Holdings, value func(Holdings) int) []int {
+	values := make([]int, len(points))
+	for i, point := range points {
+		values[i] = value(point)
+	}
+
+	return values
+}
+
+// Markdown renders the report as a Markdown document
+func (r Report) Markdown() string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "# Aconomy game %d\n\n", r.Seed)
+	fmt.Fprintf(&b, "**Winner:** %s\n\n", r.winners())
+	fmt.Fprintf(&b, "**Reason:** %s, after %d rounds\n\n", r.Outcome.Reason, r.Outcome.Rounds)
+	fmt.Fprintf(&b, "**Cost:** $%.4f for %d calls, %d prompt and %d completion tokens\n\n", r.Usage.Cost, r.Usage.Calls, r.Usage.PromptTokens, r.Usage.CompletionTokens)
+
+	b.WriteString("## Agents\n\n")
+	b.WriteString("| Agent | Gold | Wheat | Workers | Farms | Mines | Messages | Promises kept | Promises broken | Lies | Cost |\n")
+	b.WriteString("| --- | --: | --: | --: | --: | --: | --: | --: | --: | --: | --: |\n")
+	for _, agent := range r.Agents {
+		name := fmt.Sprintf("Agent %d", agent.ID)
+		if agent.Winner {
+			name += " 🏆"
+		}
+
+		buildings := buildingChange(0, agent.Final)
+		fmt.Fprintf(&b, "| %s | %d | %d | %d | %d | %d | %d | %d | %d | %d | $%.4f |\n",
+			name, agent.Final.Gold, agent.Final.Wheat, agent.Final.Workers, buildings.Farms, buildings.Mines,
+			agent.Messages, agent.Reliability.Kept, agent.Reliability.Broken, agent.Lies, agent.Usage.Cost)
+	}
+
+	b.WriteString("\n## Resources\n\n")
+	b.WriteString("| Agent | Gold | Wheat | Workers |\n")
+	b.WriteString("| --- | --- | --- | --- |\n")
+	for _, agent := range r.Agents {
+		first, last := agent.Curve[0], agent.Curve[len(agent.Curve)-1]
+		fmt.Fprintf(&b, "| Agent %d | `%s` %d → %d | `%s` %d → %d | `%s` %d → %d |\n", agent.ID,
+			sparkline(curve(agent.Curve, func(h Holdings) int { return h.Gold })), first.Gold, last.Gold,
+			sparkline(curve(agent.Curve, func(h Holdings) int { return h.Wheat })), first.Wheat, last.Wheat,
+			sparkline(curve(agent.Curve, func(h Holdings) int { return h.Workers })), first.Workers, last.Workers)
+	}
+
+	b.WriteString("\n## Buildings\n\n")
+	for _, agent := range r.Agents {
+		changes := []string{}
+		for _, change := range agent.Buildings {
+			changes = append(changes, fmt.Sprintf("round %d: %d farms, %d mines", change.Round, change.Farms, change.Mines))
+		}
+
+		fmt.Fprintf(&b, "- **Agent %d:** %s\n", agent.ID, strings.Join(changes, "; "))
+	}
+
+	b.WriteString("\n## Transfers\n\n")
+	if len(r.Transfers) == 0 {
+		b.WriteString("No resources were given between agents.\n")
+	} else {
+		b.WriteString("| From | To | Gold | Wheat | Transfers |\n")
+		b.WriteString("| --- | --- | --: | --: | --: |\n")
+		for _, t := range r.Transfers {
+			fmt.Fprintf(&b, "| Agent %d | Agent %d | %d | %d | %d |\n", t.From, t.To, t.Gold, t.Wheat, t.Count)
+		}
+	}
+
+	b.WriteString("\n## Turning points\n\n")
+	if len(r.TurningPoints) == 0 {
+		b.WriteString("Nothing stood out.\n")
+	}
+	for _, point := range r.TurningPoints {
+		fmt.Fprintf(&b, "- **Round %d:** %s\n", point.Round, point.Message)
+	}
+
+	if len(r.Lies) > 0 {
+		b.WriteString("\n## Lies\n\n")
+		for _, lie := range r.Lies {
+			fmt.Fprintf(&b, "- **Round %d, Agent %d:** claimed %q, but the truth is %d\n", lie.Turn, lie.AgentID, lie.Claim, lie.Actual)
+		}
+	}
+
+	return b.String()
+}
+
+//go:embed report.html.tmpl
+var reportTemplateText string
+
+var reportTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"buildings": func(state State) BuildingChange { return buildingChange(0, state) },
+}).Parse(reportTemplateText))
+
+// Chart dimensions in the HTML report, in SVG units
+const (
+	chartWidth   = 600
+	chartHeight  = 200
+	chartPadding = 30
+	graphSize    = 360
+)
+
+// agentColours are the colours each agent is drawn in, repeating if there are more agents
+var agentColours = []string{"#2563eb", "#dc2626", "#16a34a", "#d97706", "#7c3aed", "#db2777", "#0891b2", "#65a30d"}
+
+func agentColour(agentID int) string {
+	return agentColours[agentID%len(agentColours)]
+}
+
+// chart is one resource plotted for every agent
+type chart struct {
+	Title  string
+	Max    int
+	Rounds int
+	Lines  []chartLine
+}
+
+type chartLine struct {
+	AgentID int
+	Colour  string
+	Points  string
+}
+
+// graphNode is an agent in the transfer graph
+type graphNode struct {
+	AgentID int
+	Colour  string
+	X, Y    float64
+}
+
+// graphEdge is a transfer in the transfer graph, drawn from one agent towards another
+type graphEdge struct {
+	Transfer
+	X1, Y1, X2, Y2 float64
+	Width          float64
+}
+
+// newChart plots one of the holdings for every agent
+func (r Report) newChart(title string, value func(Holdings) int) chart {
+	c := chart{Title: title, Max: 1, Rounds: 1}
+	for _, agent := range r.Agents {
+		for _, point := range agent.Curve {
+			c.Max = max(c.Max, value(point))
+			c.Rounds = max(c.Rounds, point.Round)
+		}
+	}
+
+	for _, agent := range r.Agents {
+		points := []string{}
+		for _, point := range agent.Curve {
+			x := chartPadding + float64(point.Round)*(chartWidth-2*chartPadding)/float64(c.Rounds)
+			y := chartHeight - chartPadding - float64(value(point))*(chartHeight-2*chartPadding)/float64(c.Max)
+			points = append(points, fmt.Sprintf("%.1f,%.1f", x, y))
+		}
+
+		c.Lines = append(c.Lines, chartLine{AgentID: agent.ID, Colour: agentColour(agent.ID), Points: strings.Join(points, " ")})
+	}
+
+	return c
+}
+
+// transferGraph lays the agents out in a circle with an edge for every transfer between them
+func (r Report) transferGraph() ([]graphNode, []graphEdge) {
+	nodes := make([]graphNode, len(r.Agents))
+	radius := graphSize/2 - 40.0
+	for i := range nodes {
+		angle := 2*math.Pi*float64(i)/float64(len(nodes)) - math.Pi/2
+		nodes[i] = graphNode{
+			AgentID: i,
+			Colour:  agentColour(i),
+			X:       graphSize/2 + radius*math.Cos(angle),
+			Y:       graphSize/2 + radius*math.Sin(angle),
+		}
+	}
+
+	largest := 1
+	for _, t := range r.Transfers {
+		largest = max(largest, t.Gold+t.Wheat)
+	}
+
+	edges := []graphEdge{}
+	for _, t := range r.Transfers {
+		if t.From >= len(nodes) || t.To < 0 || t.To >= len(nodes) {
+			continue
+		}
+
+		from, to := nodes[t.From], nodes[t.To]
+
+		// Stop short of the recipient's circle so the arrow can be seen, and offset edges to the
+		// side so transfers in both directions don't overlap
+		dx, dy := to.X-from.X, to.Y-from.Y
+		length := math.Hypot(dx, dy)
+		ux, uy := dx/length, dy/length
+		ox, oy := -uy*5, ux*5
+
+		edges = append(edges, graphEdge{
+			Transfer: t,
+			X1:       from.X + ux*22 + ox,
+			Y1:       from.Y + uy*22 + oy,
+			X2:       to.X - ux*26 + ox,
+			Y2:       to.Y - uy*26 + oy,
+			Width:    1 + 5*float64(t.Gold+t.Wheat)/float64(largest),
+		})
+	}
+
+	return nodes, edges
+}
+
+// HTML renders the report as a self-contained HTML page, with charts drawn in inline SVG
+func (r Report) HTML() (string, error) {
+	nodes, edges := r.transferGraph()
+
+	data := struct {
+		Report
+		Winners   string
+		Charts    []chart
+		Nodes     []graphNode
+		Edges     []graphEdge
+		Width     int
+		Height    int
+		Padding   int
+		Right     int
+		Bottom    int
+		GraphSize int
+	}{
+		Report:  r,
+		Winners: r.winners(),
+		Charts: []chart{
+			r.newChart("Gold", func(h Holdings) int { return h.Gold }),
+			r.newChart("Wheat", func(h Holdings) int { return h.Wheat }),
+			r.newChart("Workers", func(h Holdings) int { return h.Workers }),
+		},
+		Nodes:     nodes,
+		Edges:     edges,
+		Width:     chartWidth,
+		Height:    chartHeight,
+		Padding:   chartPadding,
+		Right:     chartWidth - chartPadding,
+		Bottom:    chartHeight - chartPadding,
+		GraphSize: graphSize,
+	}
+
+	var buf bytes.Buffer
+	if err := reportTemplate.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("failed to render report: %w", err)
+	}
+
+	return buf.String(), nil
+}
+
+// WriteReport writes a game's report as Markdown to path.md and as HTML to path.html
+func WriteReport(record GameRecord, path string) error {
+	report := BuildReport(record)
+
+	if err := os.WriteFile(path+".md", []byte(report.Markdown()), 0o644); err != nil {
+		return fmt.Errorf("failed to write Markdown report: %w", err)
+	}
+
+	page, err := report.HTML()
+	if err != nil {
+		return err
+	}
+
+	if err := os.WriteFile(path+".html", []byte(page), 0o644); err != nil {
+		return fmt.Errorf("failed to write HTML report: %w", err)
+	}
+
+	return nil
+}
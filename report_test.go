@@ -0,0 +1,170 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func give(target int, resource string, amount int, success bool) ActionResult {
+	return ActionResult{
+		Action:    "give_resources",
+		Arguments: fmt.Sprintf(`{"target_agent": %d, "resource": {"type": %q, "amount": %d}}`, target, resource, amount),
+		Success:   success,
+	}
+}
+
+// testRecord is a three agent game over two rounds. Agent 1 starts richer, takes the gold lead
+// in round 1 and loses it to Agent 0 in round 2, Agent 2 is eliminated in round 1, and Agent 0
+// raids Agent 1 in round 2.
+func testRecord() GameRecord {
+	rules := DefaultRuleset()
+	rules.NumAgents = 3
+	richer := 200
+	rules.Profiles = []AgentProfile{{}, {StartingGold: &richer}}
+
+	farm := []Building{{Type: Farm, Manned: true}}
+
+	return GameRecord{
+		Seed:  9,
+		Rules: rules,
+		GameLog: GameLog{
+			{
+				Turn: 1, AgentID: 0,
+				// Production has already happened when a turn starts
+				StartState: State{Gold: 130, Wheat: 60, Workers: 2},
+				EndState:   State{Gold: 100, Wheat: 57, Workers: 2, Buildings: farm},
+				Actions: []ActionResult{
+					give(1, Gold, 5, true),
+					give(1, Wheat, 3, true),
+					give(2, Gold, 500, false),
+				},
+			},
+			{Turn: 1, AgentID: 1, StartState: State{Gold: 200}, EndState: State{Gold: 205, Wheat: 3}},
+			{Turn: 1, AgentID: 2, StartState: State{Gold: 1}, EndState: State{}},
+			{
+				Turn: 2, AgentID: 0,
+				StartState: State{Gold: 100, Wheat: 57, Workers: 2, Buildings: farm},
+				EndState:   State{Gold: 300, Wheat: 50, Workers: 2, Buildings: farm},
+				Actions:    []ActionResult{give(1, Gold, 10, true)},
+			},
+			{Turn: 2, AgentID: 1, StartState: State{Gold: 215}, EndState: State{Gold: 150}},
+		},
+		Events: []GameEvent{
+			// Events count rounds from 0
+			{Turn: 1, Kind: RaidEvent, AgentID: 0, TargetID: 1, Success: true, Message: "Agent 0 raided Agent 1 and stole 60 gold"},
+			{Turn: 1, Kind: RaidEvent, AgentID: 1, TargetID: 0, Success: false, Message: "Agent 1 raided Agent 0 and was repelled"},
+		},
+		Outcome: Outcome{Winners: []int{0}, Reason: "Agent 0 was the last agent standing", Rounds: 2},
+	}
+}
+
+func TestBuildReportCurves(t *testing.T) {
+	report := BuildReport(testRecord())
+
+	if len(report.Agents) != 3 {
+		t.Fatalf("report has %d agents, want 3", len(report.Agents))
+	}
+
+	// Round 0 comes from the rules, as the first turn starts after production
+	want := []Holdings{
+		{Round: 0, Gold: StartingGold, Wheat: StartingWheat, Workers: StartingWorkers},
+		{Round: 1, Gold: 100, Wheat: 57, Workers: 2},
+		{Round: 2, Gold: 300, Wheat: 50, Workers: 2},
+	}
+	if got := report.Agents[0].Curve; !slices.Equal(got, want) {
+		t.Errorf("Agent 0's curve is %+v, want %+v", got, want)
+	}
+
+	if start := report.Agents[1].Curve[0]; start.Gold != 200 {
+		t.Errorf("Agent 1 starts with %d gold, want their profile's 200", start.Gold)
+	}
+
+	wantBuildings := []BuildingChange{{Round: 0}, {Round: 1, Farms: 1}}
+	if got := report.Agents[0].Buildings; !slices.Equal(got, wantBuildings) {
+		t.Errorf("Agent 0's buildings are %+v, want %+v", got, wantBuildings)
+	}
+
+	if !report.Agents[0].Winner || report.Agents[1].Winner || report.Agents[0].Final.Gold != 300 {
+		t.Errorf("Agent 0 is reported as %+v", report.Agents[0])
+	}
+}
+
+func TestBuildReportTransfers(t *testing.T) {
+	report := BuildReport(testRecord())
+
+	// Failed transfers aren't counted
+	want := []Transfer{{From: 0, To: 1, Gold: 15, Wheat: 3, Count: 3}}
+	if !slices.Equal(report.Transfers, want) {
+		t.Errorf("transfers are %+v, want %+v", report.Transfers, want)
+	}
+
+	nodes, edges := report.transferGraph()
+	if len(nodes) != 3 || len(edges) != 1 || edges[0].Transfer != want[0] {
+		t.Errorf("transfer graph has %d nodes and edges %+v", len(nodes), edges)
+	}
+}
+
+func TestTurningPoints(t *testing.T) {
+	points := turningPoints(testRecord())
+
+	want := []TurningPoint{
+		{Round: 1, Message: "Agent 1 took the gold lead from Agent 0 with 205 gold"},
+		{Round: 1, Message: "Agent 2 was eliminated"},
+		{Round: 2, Message: "Agent 0 took the gold lead from Agent 1 with 300 gold"},
+		{Round: 2, Message: "Agent 0 raided Agent 1 and stole 60 gold"},
+	}
+	if !slices.Equal(points, want) {
+		t.Errorf("turning points are\n%+v\nwant\n%+v", points, want)
+	}
+}
+
+func TestReportRenders(t *testing.T) {
+	report := BuildReport(testRecord())
+
+	markdown := report.Markdown()
+	for _, want := range []string{
+		"**Winner:** Agent 0",
+		"| Agent 0 🏆 | 300 | 50 | 2 | 1 | 0 |",
+		"| Agent 0 | Agent 1 | 15 | 3 | 3 |",
+		"- **Round 1:** Agent 2 was eliminated",
+		"round 0: 0 farms, 0 mines; round 1: 1 farms, 0 mines",
+	} {
+		if !strings.Contains(markdown, want) {
+			t.Errorf("Markdown report is missing %q:\n%s", want, markdown)
+		}
+	}
+
+	page, err := report.HTML()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, want := range []string{"<svg", "<polyline", "Agent 2 was eliminated", "Agent 0 was the last agent standing"} {
+		if !strings.Contains(page, want) {
+			t.Errorf("HTML report is missing %q", want)
+		}
+	}
+}
+
+func TestOutcomeAllianceVictory(t *testing.T) {
+	game := newTestGame(t, func(r *Ruleset) {
+		r.NumAgents = 3
+		r.AllianceVictoryGold = 100
+	})
+
+	ally(t, game, 0, 2)
+	game.Agents[0].Gold, game.Agents[2].Gold = 50, 50
+	game.WinningAlliance = game.winningAlliance()
+
+	outcome := game.outcome()
+	if !slices.Equal(outcome.Winners, []int{0, 2}) || !strings.Contains(outcome.Reason, "held 100 gold between them") {
+		t.Errorf("outcome is %+v, want a win for Agents 0 and 2", outcome)
+	}
+
+	report := BuildReport(GameRecord{Rules: game.Rules, Outcome: outcome})
+	if !report.Agents[0].Winner || report.Agents[1].Winner || !report.Agents[2].Winner {
+		t.Error("the alliance's members aren't all reported as winners")
+	}
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+// maxRetryDelay caps the exponential backoff between retries
+const maxRetryDelay = 30 * time.Second
+
+// RetryingClient gives every call to the model a deadline and retries failures that may pass,
+// such as rate limits, server errors and timeouts, backing off exponentially between attempts.
+// If the model still cannot answer, the request is tried again with the fallback model.
+type RetryingClient struct {
+	client        ChatClient
+	timeout       time.Duration
+	maxRetries    int
+	retryDelay    time.Duration
+	fallbackModel string
+}
+
+// NewRetryingClient wraps a client with the timeout, retry and fallback settings of a ruleset
+func NewRetryingClient(client ChatClient, rules Ruleset) *RetryingClient {
+	return &RetryingClient{
+		client:        client,
+		timeout:       time.Duration(rules.CallTimeoutSeconds) * time.Second,
+		maxRetries:    rules.MaxRetries,
+		retryDelay:    time.Duration(rules.RetryDelayMillis) * time.Millisecond,
+		fallbackModel: rules.FallbackModel,
+	}
+}
+
+func (c *RetryingClient) CreateChatCompletion(ctx context.Context, request openai.ChatCompletionRequest) (openai.ChatCompletionResponse, error) {
+	resp, err := c.withRetries(ctx, request)
+	if err == nil || c.fallbackModel == "" || c.fallbackModel == request.Model || ctx.Err() != nil {
+		return resp, err
+	}
+
+	fmt.Printf("Model %s failed, falling back to %s: %v\n", request.Model, c.fallbackModel, err)
+
+	request.Model = c.fallbackModel
+	resp, fallbackErr := c.withRetries(ctx, request)
+	if fallbackErr != nil {
+		return resp, fmt.Errorf("%w (fallback model %s also failed: %v)", err, c.fallbackModel, fallbackErr)
+	}
+
+	return resp, nil
+}
+
+func (c *RetryingClient) withRetries(ctx context.Context, request openai.ChatCompletionRequest) (openai.ChatCompletionResponse, error) {
+	for attempt := 0; ; attempt++ {
+		resp, err := c.call(ctx, request)
+		if err == nil || attempt >= c.maxRetries || !retryable(err) || ctx.Err() != nil {
+			return resp, err
+		}
+
+		delay := c.backoff(attempt)
+		fmt.Printf("Call to %s failed (attempt %d of %d), retrying in %s: %v\n", request.Model, attempt+1, c.maxRetries+1, delay, err)
+
+		select {
+		case <-ctx.Done():
+			return resp, ctx.Err()
+		case <-time.After(delay):
+		}
+	}
+}
+
+// call makes a single attempt, abandoning it once the deadline passes
+func (c *RetryingClient) call(ctx context.Context, request openai.ChatCompletionRequest) (openai.ChatCompletionResponse, error) {
+	if c.timeout <= 0 {
+		return c.client.CreateChatCompletion(ctx, request)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	return c.client.CreateChatCompletion(ctx, request)
+}
+
+// backoff doubles the delay after each failed attempt, up to maxRetryDelay
+func (c *RetryingClient) backoff(attempt int) time.Duration {
+	delay := c.retryDelay
+	for i := 0; i < attempt && delay < maxRetryDelay; i++ {
+		delay *= 2
+	}
+
+	return min(delay, maxRetryDelay)
+}
+
+// retryable reports whether a failed call is worth trying again: rate limits, server errors,
+// timeouts and network failures are, while bad requests and cache misses are not
+func retryable(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+
+	var apiErr *openai.APIError
+	if errors.As(err, &apiErr) {
+		return retryableStatus(apiErr.HTTPStatusCode)
+	}
+
+	var requestErr *openai.RequestError
+	if errors.As(err, &requestErr) {
+		return retryableStatus(requestErr.HTTPStatusCode)
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+func retryableStatus(status int) bool {
+	return status == http.StatusTooManyRequests || status >= http.StatusInternalServerError
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+// flakyClient fails each model's first calls with the given errors, then answers
+type flakyClient struct {
+	failures map[string][]error
+	calls    []string
+}
+
+func (c *flakyClient) CreateChatCompletion(ctx context.Context, request openai.ChatCompletionRequest) (openai.ChatCompletionResponse, error) {
+	c.calls = append(c.calls, request.Model)
+
+	if errs := c.failures[request.Model]; len(errs) > 0 {
+		c.failures[request.Model] = errs[1:]
+		if errs[0] == context.DeadlineExceeded {
+			<-ctx.Done()
+			return openai.ChatCompletionResponse{}, ctx.Err()
+		}
+		return openai.ChatCompletionResponse{}, errs[0]
+	}
+
+	return openai.ChatCompletionResponse{Model: request.Model}, nil
+}
+
+func apiError(status int) error {
+	return &openai.APIError{HTTPStatusCode: status, Message: http.StatusText(status)}
+}
+
+func retryRules(maxRetries int, fallbackModel string) Ruleset {
+	rules := DefaultRuleset()
+	rules.MaxRetries = maxRetries
+	rules.RetryDelayMillis = 1
+	rules.FallbackModel = fallbackModel
+	return rules
+}
+
+func TestRetryingClientBackoff(t *testing.T) {
+	client := &RetryingClient{retryDelay: time.Second}
+
+	want := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second, 16 * time.Second, maxRetryDelay, maxRetryDelay}
+	for attempt, delay := range want {
+		if got := client.backoff(attempt); got != delay {
+			t.Errorf("attempt %d: backoff %s, want %s", attempt, got, delay)
+		}
+	}
+
+	// Large attempt counts must not overflow the delay
+	if got := client.backoff(100); got != maxRetryDelay {
+		t.Errorf("attempt 100: backoff %s, want %s", got, maxRetryDelay)
+	}
+}
+
+func TestRetryingClientRetries(t *testing.T) {
+	tests := []struct {
+		name      string
+		failures  []error
+		wantCalls int
+		wantErr   bool
+	}{
+		{"success", nil, 1, false},
+		{"rate limited then success", []error{apiError(http.StatusTooManyRequests)}, 2, false},
+		{"server errors then success", []error{apiError(http.StatusInternalServerError), apiError(http.StatusBadGateway)}, 3, false},
+		{"timeout then success", []error{context.DeadlineExceeded}, 2, false},
+		{"out of retries", []error{apiError(http.StatusServiceUnavailable), apiError(http.StatusServiceUnavailable), apiError(http.StatusServiceUnavailable)}, 3, true},
+		{"bad request is not retried", []error{apiError(http.StatusBadRequest)}, 1, true},
+		{"cache miss is not retried", []error{ErrCacheMiss}, 1, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			model := &flakyClient{failures: map[string][]error{openai.GPT4o: tt.failures}}
+			client := NewRetryingClient(model, retryRules(2, ""))
+			client.timeout = 50 * time.Millisecond
+
+			_, err := client.CreateChatCompletion(context.Background(), openai.ChatCompletionRequest{Model: openai.GPT4o})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error: %t", err, tt.wantErr)
+			}
+
+			if len(model.calls) != tt.wantCalls {
+				t.Errorf("called the model %d times, want %d", len(model.calls), tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestRetryingClientFallback(t *testing.T) {
+	unavailable := []error{apiError(http.StatusServiceUnavailable), apiError(http.StatusServiceUnavailable)}
+
+	model := &flakyClient{failures: map[string][]error{openai.GPT4o: unavailable}}
+	client := NewRetryingClient(model, retryRules(1, openai.GPT4oMini))
+
+	resp, err := client.CreateChatCompletion(context.Background(), openai.ChatCompletionRequest{Model: openai.GPT4o})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.Model != openai.GPT4oMini {
+		t.Errorf("answered by %s, want the fallback model %s", resp.Model, openai.GPT4oMini)
+	}
+
+	want := []string{openai.GPT4o, openai.GPT4o, openai.GPT4oMini}
+	if len(model.calls) != len(want) {
+		t.Fatalf("called models %v, want %v", model.calls, want)
+	}
+	for i := range want {
+		if model.calls[i] != want[i] {
+			t.Fatalf("called models %v, want %v", model.calls, want)
+		}
+	}
+}
+
+func TestRetryingClientFallbackFails(t *testing.T) {
+	model := &flakyClient{failures: map[string][]error{
+		openai.GPT4o:     {apiError(http.StatusServiceUnavailable)},
+		openai.GPT4oMini: {apiError(http.StatusServiceUnavailable)},
+	}}
+	client := NewRetryingClient(model, retryRules(0, openai.GPT4oMini))
+
+	_, err := client.CreateChatCompletion(context.Background(), openai.ChatCompletionRequest{Model: openai.GPT4o})
+
+	var apiErr *openai.APIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("got error %v, want the primary model's API error", err)
+	}
+
+	if len(model.calls) != 2 {
+		t.Errorf("called the model %d times, want 2", len(model.calls))
+	}
+}
+
+// TestRetryingClientNoFallbackToSelf doesn't retry a request that already used the fallback model
+func TestRetryingClientNoFallbackToSelf(t *testing.T) {
+	model := &flakyClient{failures: map[string][]error{openai.GPT4oMini: {apiError(http.StatusServiceUnavailable)}}}
+	client := NewRetryingClient(model, retryRules(0, openai.GPT4oMini))
+
+	if _, err := client.CreateChatCompletion(context.Background(), openai.ChatCompletionRequest{Model: openai.GPT4oMini}); err == nil {
+		t.Fatal("expected an error")
+	}
+
+	if len(model.calls) != 1 {
+		t.Errorf("called the model %d times, want 1", len(model.calls))
+	}
+}
@@ -0,0 +1,433 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// Ruleset holds the tunable parameters of a game. DefaultRuleset mirrors the
+// game constants, so a game started without a ruleset file plays as before.
+type Ruleset struct {
+	NumAgents int
+
+	StartingGold     int
+	StartingWorkers  int
+	StartingBuilding int
+	StartingWheat    int
+
+	WorkerCost int
+	FarmCost   int
+	MineCost   int
+
+	WheatPerWorker int
+	WheatDecayRate float64
+
+	FarmProduction int
+	MineProduction int
+
+	ActionsPerTurn int
+	// InvalidActionRetries is how many malformed or invalid tool calls an
+	// agent may correct each turn without using up an action
+	InvalidActionRetries int
+
+	// TurnMode is either SequentialTurns, where agents act one after another,
+	// or SimultaneousTurns, where agents plan their actions at the same time
+	// and the engine resolves them together at the end of the round.
+	// MaxConcurrency bounds the number of agents planning at once; 0 means no
+	// limit.
+	TurnMode       string
+	MaxConcurrency int
+	// TurnOrder is one of FixedOrder, RotatingOrder, ShuffledOrder or
+	// PoorestFirstOrder. When empty, turns are fixed in sequential mode and
+	// rotate in simultaneous mode, where the order breaks ties between
+	// contested actions.
+	TurnOrder string
+
+	WinningGoldAmount int
+	MaxTurns          int
+
+	RaidGoldCost      int
+	RaidBaseSuccess   float64
+	RaidWorkerBonus   float64
+	RaidStealFraction float64
+
+	SabotageCost        int
+	SabotageBaseSuccess float64
+	SabotageDuration    int
+
+	WallCost     int
+	WallDefence  float64
+	GuardCost    int
+	GuardDefence float64
+	MaxDefence   float64
+
+	// AllianceSharedVisibility shows each agent the resources of their allies
+	AllianceSharedVisibility bool
+	// AllianceVictoryGold is the combined gold at which an alliance wins the
+	// game together. Team victory is disabled when it is 0.
+	AllianceVictoryGold int
+
+	// Governance lets agents propose and vote on policies, and taxes production
+	// at TaxRate into a shared treasury. Passed policies change these rules for
+	// the rest of the game.
+	Governance bool
+	TaxRate    float64
+
+	// Profiles gives agents asymmetric starting conditions and skills. The
+	// first profile applies to Agent 0, the second to Agent 1, and so on.
+	Profiles []AgentProfile
+
+	// Seed drives all of the engine's randomness and is forwarded to the model
+	// as its sampling seed. A random seed is chosen when it is 0.
+	Seed int64
+	// Temperature is the model's sampling temperature. The provider's default
+	// is used when it is 0.
+	Temperature float32
+
+	// Model is the model agents play with. If a call still fails after
+	// MaxRetries retries, backing off from RetryDelayMillis, it is tried again
+	// with FallbackModel when one is set. Each attempt is abandoned after
+	// CallTimeoutSeconds; 0 means no deadline.
+	Model              string
+	FallbackModel      string
+	CallTimeoutSeconds int
+	MaxRetries         int
+	RetryDelayMillis   int
+	// MaxCost ends the game once its estimated cost in dollars passes it. There
+	// is no limit when it is 0.
+	MaxCost float64
+	// MaxForfeits is how many turns in a row an agent may forfeit because the
+	// model could not be reached before the game is ended. 0 means no limit.
+	MaxForfeits int
+
+	// ContextWindowTurns is how many of an agent's previous turns are kept in
+	// their prompt verbatim; the whole history is kept when it is 0. Every
+	// SummaryEveryTurns turns, older turns are folded into a rolling summary,
+	// written by the model when SummaryMode is LLMSummary or condensed from
+	// the game log when it is CompressedSummary. They are summarised early if
+	// the prompt grows past ContextTokenLimit tokens, or three quarters of the
+	// model's context window when it is 0.
+	ContextWindowTurns int
+	SummaryEveryTurns  int
+	SummaryMode        string
+	ContextTokenLimit  int
+
+	// FreeCallsPerTurn is how many calls to tools that don't use an action,
+	// such as write_note, an agent may make each turn. Calls beyond it count
+	// as actions.
+	FreeCallsPerTurn int
+	// NotebookSize is how many notes an agent's notebook holds before the
+	// oldest are dropped; 0 means no limit
+	NotebookSize int
+	// Visibility is how much agents can find out about each other with the
+	// query tools: FullVisibility, PartialVisibility or FogOfWar
+	Visibility string
+	// InformationModel is how accurately agents see each other's holdings, in
+	// the broadcasts at the end of each turn and in the query tools:
+	// ExactInformation, DelayedInformation (InformationDelay rounds behind),
+	// NoisyInformation (each figure off by up to InformationNoise) or
+	// HiddenInformation
+	InformationModel string
+	InformationDelay int
+	InformationNoise float64
+
+	// NegotiationRounds is how many rounds of negotiation agents have before
+	// anyone acts each round, in which they can message each other without
+	// using an action. Each may send up to NegotiationMessages messages a
+	// negotiation round, and the phase ends once it has used NegotiationTokens
+	// tokens. There is no negotiation phase when NegotiationRounds is 0, and
+	// no token limit when NegotiationTokens is 0.
+	NegotiationRounds   int
+	NegotiationMessages int
+	NegotiationTokens   int
+
+	// DetectDeception checks every message as it is sent for false claims
+	// about the sender's holdings or the rules, recording an event for each
+	// lie. Game records are always analysed for lies afterwards.
+	DetectDeception bool
+
+	// StructuredStrategy asks agents for their strategy and reflection as JSON
+	// matching a schema of goals, planned actions, beliefs about each other
+	// agent and confidence, recorded on each turn. The model must support
+	// structured outputs.
+	StructuredStrategy bool
+
+	// PromptDir is a directory of prompt templates: system.tmpl, turn.tmpl and
+	// personas/<name>.tmpl. The built in templates are used for any file it
+	// doesn't have, or for all of them when it is empty.
+	PromptDir string
+
+	// SeasonLength is the number of turns each season lasts. Seasons are
+	// disabled when it is 0.
+	SeasonLength int
+	Seasons      []Season
+}
+
+// Season modulates production and wheat decay while it is active. Each multiplier scales the
+// ruleset's own value.
+type Season struct {
+	Name                 string
+	FarmMultiplier       float64
+	MineMultiplier       float64
+	WheatDecayMultiplier float64
+}
+
+// DefaultRuleset returns the standard game rules
+func DefaultRuleset() Ruleset {
+	return Ruleset{
+		NumAgents: NumAgents,
+
+		StartingGold:     StartingGold,
+		StartingWorkers:  StartingWorkers,
+		StartingBuilding: StartingBuilding,
+		StartingWheat:    StartingWheat,
+
+		WorkerCost: WorkerCost,
+		FarmCost:   FarmCost,
+		MineCost:   MineCost,
+
+		WheatPerWorker: WheatPerWorker,
+		WheatDecayRate: WheatDecayRate,
+
+		FarmProduction: FarmProduction,
+		MineProduction: MineProduction,
+
+		ActionsPerTurn:       ActionsPerTurn,
+		InvalidActionRetries: InvalidActionRetries,
+
+		TurnMode: SequentialTurns,
+
+		WinningGoldAmount: WinningGoldAmount,
+		MaxTurns:          MaxTurns,
+
+		RaidGoldCost:      RaidGoldCost,
+		RaidBaseSuccess:   RaidBaseSuccess,
+		RaidWorkerBonus:   RaidWorkerBonus,
+		RaidStealFraction: RaidStealFraction,
+
+		SabotageCost:        SabotageCost,
+		SabotageBaseSuccess: SabotageBaseSuccess,
+		SabotageDuration:    SabotageDuration,
+
+		WallCost:     WallCost,
+		WallDefence:  WallDefence,
+		GuardCost:    GuardCost,
+		GuardDefence: GuardDefence,
+		MaxDefence:   MaxDefence,
+
+		Model:              DefaultModel,
+		CallTimeoutSeconds: CallTimeoutSeconds,
+		MaxRetries:         MaxRetries,
+		RetryDelayMillis:   RetryDelayMillis,
+		MaxForfeits:        MaxForfeits,
+
+		ContextWindowTurns: ContextWindowTurns,
+		SummaryEveryTurns:  SummaryEveryTurns,
+		SummaryMode:        LLMSummary,
+
+		FreeCallsPerTurn: FreeCallsPerTurn,
+		NotebookSize:     NotebookSize,
+		Visibility:       FullVisibility,
+		InformationModel: ExactInformation,
+		InformationDelay: InformationDelay,
+		InformationNoise: InformationNoise,
+
+		NegotiationRounds:   0,
+		NegotiationMessages: NegotiationMessages,
+
+		SeasonLength: 0,
+		Seasons:      defaultSeasons(),
+	}
+}
+
+// defaultSeasons is a four season year: a bumper summer harvest that spoils
+// quickly, and a winter in which farms lie fallow
+func defaultSeasons() []Season {
+	return []Season{
+		{Name: "Spring", FarmMultiplier: 1, MineMultiplier: 1, WheatDecayMultiplier: 1},
+		{Name: "Summer", FarmMultiplier: 1.5, MineMultiplier: 1, WheatDecayMultiplier: 1.5},
+		{Name: "Autumn", FarmMultiplier: 1, MineMultiplier: 1, WheatDecayMultiplier: 1},
+		{Name: "Winter", FarmMultiplier: 0, MineMultiplier: 0.5, WheatDecayMultiplier: 0.5},
+	}
+}
+
+// LoadRuleset reads a JSON ruleset from disk. Fields missing from the file keep
+// their default values.
+func LoadRuleset(path string) (Ruleset, error) {
+	rules := DefaultRuleset()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return rules, fmt.Errorf("failed to read ruleset: %w", err)
+	}
+
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return rules, fmt.Errorf("failed to parse ruleset: %w", err)
+	}
+
+	if err := rules.Validate(); err != nil {
+		return rules, fmt.Errorf("invalid ruleset: %w", err)
+	}
+
+	return rules, nil
+}
+
+// Validate checks that the ruleset describes a playable game
+func (r Ruleset) Validate() error {
+	if r.NumAgents < 1 {
+		return fmt.Errorf("at least one agent is required")
+	}
+
+	if r.ActionsPerTurn < 1 {
+		return fmt.Errorf("agents must have at least one action per turn")
+	}
+
+	if r.StartingGold < 0 || r.StartingWheat < 0 || r.StartingWorkers < 0 || r.StartingBuilding < 0 {
+		return fmt.Errorf("starting resources cannot be negative")
+	}
+
+	for _, cost := range []int{r.WorkerCost, r.FarmCost, r.MineCost, r.RaidGoldCost, r.SabotageCost, r.WallCost, r.GuardCost} {
+		if cost < 0 {
+			return fmt.Errorf("costs cannot be negative")
+		}
+	}
+
+	if r.FarmProduction < 0 || r.MineProduction < 0 {
+		return fmt.Errorf("production cannot be negative")
+	}
+
+	if r.WheatPerWorker <= 0 {
+		return fmt.Errorf("wheat per worker must be greater than 0")
+	}
+
+	if r.WheatDecayRate < 0 || r.WheatDecayRate > 1 {
+		return fmt.Errorf("wheat decay rate must be between 0 and 1")
+	}
+
+	if r.InvalidActionRetries < 0 {
+		return fmt.Errorf("invalid action retries cannot be negative")
+	}
+
+	if r.TurnMode != SequentialTurns && r.TurnMode != SimultaneousTurns {
+		return fmt.Errorf("unknown turn mode %q", r.TurnMode)
+	}
+
+	if !validTurnOrder(r.TurnOrder) {
+		return fmt.Errorf("unknown turn order %q", r.TurnOrder)
+	}
+
+	if r.MaxConcurrency < 0 {
+		return fmt.Errorf("max concurrency cannot be negative")
+	}
+
+	if r.RaidStealFraction < 0 || r.RaidStealFraction > 1 {
+		return fmt.Errorf("raid steal fraction must be between 0 and 1")
+	}
+
+	if r.AllianceVictoryGold < 0 {
+		return fmt.Errorf("alliance victory gold cannot be negative")
+	}
+
+	if r.TaxRate < 0 || r.TaxRate > 1 {
+		return fmt.Errorf("tax rate must be between 0 and 1")
+	}
+
+	if len(r.Profiles) > r.NumAgents {
+		return fmt.Errorf("%d profiles defined for %d agents", len(r.Profiles), r.NumAgents)
+	}
+
+	for i, profile := range r.Profiles {
+		if err := profile.Validate(); err != nil {
+			return fmt.Errorf("profile %d: %w", i, err)
+		}
+	}
+
+	if r.Model == "" {
+		return fmt.Errorf("a model is required")
+	}
+
+	if r.CallTimeoutSeconds < 0 || r.MaxRetries < 0 || r.RetryDelayMillis < 0 || r.MaxForfeits < 0 {
+		return fmt.Errorf("call timeout, retries, retry delay and forfeits cannot be negative")
+	}
+
+	if r.MaxCost < 0 {
+		return fmt.Errorf("max cost cannot be negative")
+	}
+
+	if r.ContextWindowTurns < 0 || r.SummaryEveryTurns < 0 || r.ContextTokenLimit < 0 {
+		return fmt.Errorf("context window, summary cadence and token limit cannot be negative")
+	}
+
+	if r.FreeCallsPerTurn < 0 || r.NotebookSize < 0 {
+		return fmt.Errorf("free calls per turn and notebook size cannot be negative")
+	}
+
+	if r.Visibility != FullVisibility && r.Visibility != PartialVisibility && r.Visibility != FogOfWar {
+		return fmt.Errorf("unknown visibility %q", r.Visibility)
+	}
+
+	if r.InformationModel != ExactInformation && r.InformationModel != DelayedInformation &&
+		r.InformationModel != NoisyInformation && r.InformationModel != HiddenInformation {
+		return fmt.Errorf("unknown information model %q", r.InformationModel)
+	}
+
+	if r.InformationDelay < 0 {
+		return fmt.Errorf("information delay cannot be negative")
+	}
+
+	if r.InformationNoise < 0 || r.InformationNoise > 1 {
+		return fmt.Errorf("information noise must be between 0 and 1")
+	}
+
+	if r.NegotiationRounds < 0 || r.NegotiationTokens < 0 {
+		return fmt.Errorf("negotiation rounds and tokens cannot be negative")
+	}
+
+	if r.NegotiationRounds > 0 && r.NegotiationMessages <= 0 {
+		return fmt.Errorf("negotiation messages must be greater than 0 when negotiation is enabled")
+	}
+
+	if r.SummaryMode != LLMSummary && r.SummaryMode != CompressedSummary {
+		return fmt.Errorf("unknown summary mode %q", r.SummaryMode)
+	}
+
+	templates, err := LoadPromptTemplates(r)
+	if err != nil {
+		return err
+	}
+
+	if err := templates.check(r); err != nil {
+		return err
+	}
+
+	if r.SeasonLength < 0 {
+		return fmt.Errorf("season length cannot be negative")
+	}
+
+	if r.SeasonLength > 0 && len(r.Seasons) == 0 {
+		return fmt.Errorf("seasons are enabled but none are defined")
+	}
+
+	for _, season := range r.Seasons {
+		if season.FarmMultiplier < 0 || season.MineMultiplier < 0 || season.WheatDecayMultiplier < 0 {
+			return fmt.Errorf("season %q: multipliers cannot be negative", season.Name)
+		}
+	}
+
+	return nil
+}
+
+// SeasonsEnabled reports whether the seasonal cycle is active
+func (r Ruleset) SeasonsEnabled() bool {
+	return r.SeasonLength > 0 && len(r.Seasons) > 0
+}
+
+// SeasonAt returns the season active on the given (zero-indexed) game turn and
+// how many turns it has left including the current one
+func (r Ruleset) SeasonAt(turn int) (Season, int) {
+	index := (turn / r.SeasonLength) % len(r.Seasons)
+	remaining := r.SeasonLength - turn%r.SeasonLength
+
+	return r.Seasons[index], remaining
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestSeasonalProduction(t *testing.T) {
+	game := newTestGame(t, func(r *Ruleset) {
+		r.SeasonLength = 2
+		r.WheatDecayRate = 0.8
+	})
+
+	tests := []struct {
+		turn      int
+		season    string
+		farm      int
+		mine      int
+		decayRate float64
+	}{
+		{0, "Spring", 3, 5, 0.8},
+		{1, "Spring", 3, 5, 0.8},
+		// Summer's decay multiplier would spoil more than all of the wheat
+		{2, "Summer", 4, 5, 1},
+		{5, "Autumn", 3, 5, 0.8},
+		{6, "Winter", 0, 2, 0.4},
+		{8, "Spring", 3, 5, 0.8},
+	}
+
+	for _, tt := range tests {
+		game.CurrentTurn = tt.turn
+
+		season, _ := game.currentSeason()
+		if season.Name != tt.season {
+			t.Errorf("turn %d: season %s, want %s", tt.turn, season.Name, tt.season)
+		}
+
+		if got := game.farmProduction(0); got != tt.farm {
+			t.Errorf("turn %d: farms produce %d, want %d", tt.turn, got, tt.farm)
+		}
+
+		if got := game.mineProduction(0); got != tt.mine {
+			t.Errorf("turn %d: mines produce %d, want %d", tt.turn, got, tt.mine)
+		}
+
+		if got := game.wheatDecayRate(); math.Abs(got-tt.decayRate) > 1e-9 {
+			t.Errorf("turn %d: wheat decay rate %v, want %v", tt.turn, got, tt.decayRate)
+		}
+	}
+}
+
+func TestWithoutSeasons(t *testing.T) {
+	game := newTestGame(t, nil)
+
+	for turn := 0; turn < 8; turn++ {
+		game.CurrentTurn = turn
+		if game.farmProduction(0) != FarmProduction || game.mineProduction(0) != MineProduction || game.wheatDecayRate() != WheatDecayRate {
+			t.Fatalf("turn %d: production changed without seasons", turn)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(*Ruleset)
+	}{
+		{"no agents", func(r *Ruleset) { r.NumAgents = 0 }},
+		{"negative starting gold", func(r *Ruleset) { r.StartingGold = -1 }},
+		{"negative cost", func(r *Ruleset) { r.FarmCost = -10 }},
+		{"negative production", func(r *Ruleset) { r.MineProduction = -1 }},
+		{"no wheat per worker", func(r *Ruleset) { r.WheatPerWorker = 0 }},
+		{"wheat decay above 1", func(r *Ruleset) { r.WheatDecayRate = 1.5 }},
+		{"negative season length", func(r *Ruleset) { r.SeasonLength = -1 }},
+		{"seasons without any defined", func(r *Ruleset) { r.SeasonLength = 1; r.Seasons = nil }},
+		{"negative season multiplier", func(r *Ruleset) { r.Seasons[3].WheatDecayMultiplier = -0.5 }},
+	}
+
+	if err := DefaultRuleset().Validate(); err != nil {
+		t.Fatalf("default ruleset is invalid: %v", err)
+	}
+
+	for _, tt := range tests {
+		rules := DefaultRuleset()
+		tt.change(&rules)
+
+		if err := rules.Validate(); err == nil {
+			t.Errorf("%s: ruleset was accepted", tt.name)
+		}
+	}
+}
@@ -0,0 +1,264 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+// Turn modes
+const (
+	SequentialTurns   = "sequential"
+	SimultaneousTurns = "simultaneous"
+)
+
+// actionPhases orders the resolution of simultaneous actions. Talking and defending come before
+// trading and building, and attacks land last against the defences and stockpiles that result.
+var actionPhases = map[string]int{
+	"send_message":          0,
+	"send_alliance_message": 0,
+	"broadcast_message":     0,
+	"create_group_chat":     0,
+	"invite_to_channel":     0,
+	"post_to_channel":       0,
+	"make_promise":          0,
+	"propose_alliance":      0,
+	"accept_alliance":       0,
+	"leave_alliance":        0,
+	"propose_policy":        0,
+	"vote":                  0,
+	"build_wall":            1,
+	"hire_guards":           1,
+	"give_resources":        2,
+	"buy_worker":            3,
+	"buy_building":          3,
+	"man_building":          3,
+	"unman_building":        3,
+	"raid":                  4,
+	"sabotage_building":     4,
+}
+
+// submittedAction is an action an agent has chosen, waiting to be resolved
+type submittedAction struct {
+	agent    *Agent
+	seat     int
+	index    int
+	toolCall openai.ToolCall
+}
+
+// RunSimultaneousRound has every agent plan their actions at the same time, then resolves all of
+// the actions together. No agent sees another's actions for the round before choosing their own.
+// Within each phase, actions are resolved in turn order.
+func RunSimultaneousRound(game *Game, order []int) {
+	active := []*Agent{}
+	for _, i := range order {
+		if !game.Agents[i].Lost {
+			active = append(active, &game.Agents[i])
+		}
+	}
+
+	// Start-of-turn actions touch shared state such as the treasury, so run them in order
+	for _, agent := range active {
+		if err := agent.StartTurn(game); err != nil {
+			fmt.Printf("Agent %d's turn could not start, ending the game: %v\n", agent.ID, err)
+			game.End()
+			return
+		}
+		agent.AddTurnLog("All agents are choosing their actions at the same time this round. Your actions will be resolved together with everyone else's at the end of the round.")
+	}
+
+	turns := make([]*AgentTurn, len(active))
+	submitted := make([][]openai.ToolCall, len(active))
+
+	forEachConcurrently(game, active, func(i int, agent *Agent) {
+		turns[i], submitted[i] = agent.PlanTurn(game, game.rulesFor(agent.ID).ActionsPerTurn)
+	})
+
+	// Agents whose model could not be reached forfeit the round. Forfeits touch shared state, so
+	// they are handled once planning has finished.
+	for i, agent := range active {
+		if turns[i].Error != nil {
+			game.forfeitTurn(agent, turns[i], turns[i].Error)
+		}
+	}
+
+	// If the game has ended the actions are not resolved, but the turns are still recorded
+	if !game.ended() {
+		actions := []submittedAction{}
+		for i, agent := range active {
+			for j, toolCall := range submitted[i] {
+				actions = append(actions, submittedAction{agent: agent, seat: i, index: j, toolCall: toolCall})
+			}
+		}
+
+		sort.SliceStable(actions, func(i, j int) bool {
+			pi, pj := actionPhases[actions[i].toolCall.Function.Name], actionPhases[actions[j].toolCall.Function.Name]
+			if pi != pj {
+				return pi < pj
+			}
+
+			if actions[i].seat != actions[j].seat {
+				return actions[i].seat < actions[j].seat
+			}
+
+			return actions[i].index < actions[j].index
+		})
+
+		for _, action := range actions {
+			result := action.agent.TakeAction(game, action.toolCall)
+			turns[action.seat].Actions = append(turns[action.seat].Actions, result)
+			action.agent.AddTurnLog(result.String())
+		}
+
+		forEachConcurrently(game, active, func(i int, agent *Agent) {
+			if turns[i].Error != nil {
+				return
+			}
+
+			err := agent.Reflect(game, turns[i])
+			if err != nil {
+				turns[i].Error = err
+			}
+		})
+
+		for i, agent := range active {
+			if turns[i].Error != nil && !turns[i].Forfeited {
+				game.forfeitTurn(agent, turns[i], turns[i].Error)
+			}
+		}
+	}
+
+	for i, agent := range active {
+		if turns[i].Error == nil {
+			agent.forfeits = 0
+		}
+	}
+
+	for i, agent := range active {
+		for _, event := range game.Events {
+			if event.Turn == game.CurrentTurn && event.AgentID == agent.ID {
+				turns[i].Events = append(turns[i].Events, event)
+			}
+		}
+
+		agent.EndTurn(game)
+
+		fmt.Printf("Agent %d's turn ended\n", agent.ID)
+
+		if game.recordTurn(agent, *turns[i]) {
+			return
+		}
+	}
+}
+
+// PlanTurn asks the agent for their strategy and actions without resolving them. Agents plan at
+// the same time, so planning only writes to the agent's own prompt, notes and usage: every other
+// action is submitted to be resolved once planning is over, and free tools, which read shared
+// state, are used one agent at a time.
+func (a *Agent) PlanTurn(g *Game, actionCount int) (*AgentTurn, []openai.ToolCall) {
+	a.manageContext(g)
+	turn := a.newTurn()
+	actions := []openai.ToolCall{}
+
+	err := a.Strategise(g, &turn)
+	if err != nil {
+		turn.Error = err
+		return &turn, nil
+	}
+
+	actionsLeft := actionCount
+	retriesLeft := g.rulesFor(a.ID).InvalidActionRetries
+	freeCallsLeft := g.rulesFor(a.ID).FreeCallsPerTurn
+
+	for actionsLeft > 0 {
+		toolCalls, err := a.ChooseActions(g, actionsLeft)
+		if err != nil {
+			turn.Error = err
+			return &turn, nil
+		}
+
+		for _, toolCall := range toolCalls {
+			if actionsLeft <= 0 {
+				result := notTaken(toolCall)
+				a.AddToolResult(toolCall.ID, result.content())
+				turn.Actions = append(turn.Actions, result)
+				continue
+			}
+
+			// Invalid calls are caught now, while the agent can still correct them
+			if _, err := a.parseAction(g, toolCall); err != nil {
+				result := invalidAction(toolCall, err)
+				if retriesLeft > 0 {
+					retriesLeft--
+					result.allowRetry(retriesLeft)
+				} else {
+					actionsLeft--
+				}
+
+				a.AddToolResult(toolCall.ID, result.content())
+				turn.Actions = append(turn.Actions, result)
+				continue
+			}
+
+			// Free tools don't change the game, so they are taken straight away
+			if freeTools[toolCall.Function.Name] {
+				g.planning.Lock()
+				result := a.TakeAction(g, toolCall)
+				g.planning.Unlock()
+
+				if freeCallsLeft > 0 {
+					freeCallsLeft--
+				} else {
+					actionsLeft--
+				}
+
+				a.AddToolResult(toolCall.ID, result.content())
+				turn.Actions = append(turn.Actions, result)
+				continue
+			}
+
+			actionsLeft--
+
+			if toolCall.Function.Name == "end_turn" {
+				result := a.TakeAction(g, toolCall)
+				a.AddToolResult(toolCall.ID, result.content())
+				turn.Actions = append(turn.Actions, result)
+				actionsLeft = 0
+				continue
+			}
+
+			actions = append(actions, toolCall)
+			a.AddToolResult(toolCall.ID, "Your action has been submitted and will be resolved at the end of the round")
+		}
+	}
+
+	return &turn, actions
+}
+
+// forEachConcurrently runs fn for each agent in its own goroutine, with at most
+// MaxConcurrency running at once
+func forEachConcurrently(g *Game, agents []*Agent, fn func(i int, agent *Agent)) {
+	limit := g.Rules.MaxConcurrency
+	if limit <= 0 {
+		limit = len(agents)
+	}
+
+	sem := make(chan struct{}, limit)
+	var wg sync.WaitGroup
+
+	for i, agent := range agents {
+		wg.Add(1)
+		sem <- struct{}{}
+
+		go func(i int, agent *Agent) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			fn(i, agent)
+		}(i, agent)
+	}
+
+	wg.Wait()
+}
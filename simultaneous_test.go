@@ -0,0 +1,121 @@
+package main
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+// TestForEachConcurrently checks every agent is visited once, with no more than MaxConcurrency
+// running at a time
+func TestForEachConcurrently(t *testing.T) {
+	for _, limit := range []int{0, 1, 2} {
+		game := newTestGame(t, func(r *Ruleset) {
+			r.NumAgents = 5
+			r.MaxConcurrency = limit
+		})
+
+		agents := []*Agent{}
+		for i := range game.Agents {
+			agents = append(agents, &game.Agents[i])
+		}
+
+		var mu sync.Mutex
+		running, peak := 0, 0
+		visited := make([]int, len(agents))
+
+		forEachConcurrently(game, agents, func(i int, agent *Agent) {
+			mu.Lock()
+			running++
+			peak = max(peak, running)
+			mu.Unlock()
+
+			time.Sleep(5 * time.Millisecond)
+			visited[i] += agent.ID + 1
+
+			mu.Lock()
+			running--
+			mu.Unlock()
+		})
+
+		for i, v := range visited {
+			if v != i+1 {
+				t.Errorf("limit %d: agent %d visited with the wrong agent or more than once", limit, i)
+			}
+		}
+
+		want := limit
+		if limit == 0 {
+			want = len(agents)
+		}
+		if peak > want {
+			t.Errorf("limit %d: %d agents ran at once", limit, peak)
+		}
+	}
+}
+
+// everyTool uses each free tool, then submits actions that touch other agents
+func everyTool(int) []openai.FunctionCall {
+	return []openai.FunctionCall{
+		{Name: "write_note", Arguments: `{"note": "remember the plan"}`},
+		{Name: "read_notes", Arguments: `{}`},
+		{Name: "inspect_agent", Arguments: `{"target_agent": 1}`},
+		{Name: "list_buildings", Arguments: `{}`},
+		{Name: "view_price_history", Arguments: `{}`},
+		{Name: "view_my_ledger", Arguments: `{"turns": 2}`},
+		{Name: "list_channels", Arguments: `{}`},
+		{Name: "give_resources", Arguments: `{"target_agent": 2, "resource": {"type": "Gold", "amount": 1}}`},
+		{Name: "send_message", Arguments: `{"target_agent": 0, "message": "I have 50 gold"}`},
+		{Name: "end_turn", Arguments: `{}`},
+	}
+}
+
+// TestSimultaneousRoundRace plays simultaneous rounds in which every agent uses the free tools
+// while planning. Run with -race to check planning doesn't share state unsafely.
+func TestSimultaneousRoundRace(t *testing.T) {
+	for _, information := range []string{ExactInformation, DelayedInformation, NoisyInformation} {
+		t.Run(information, func(t *testing.T) {
+			rules := DefaultRuleset()
+			rules.TurnMode = SimultaneousTurns
+			rules.MaxTurns = 3
+			rules.ActionsPerTurn = 3
+			rules.FreeCallsPerTurn = 10
+			rules.InformationModel = information
+			rules.ContextWindowTurns = 1
+			rules.SummaryEveryTurns = 1
+			rules.SummaryMode = CompressedSummary
+			rules.Seed = 1
+
+			game, err := NewGame(nil, &stubClient{actions: everyTool}, rules)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			RunGame(game)
+
+			if game.CurrentTurn != rules.MaxTurns {
+				t.Fatalf("game ended after %d rounds, want %d", game.CurrentTurn, rules.MaxTurns)
+			}
+
+			if len(game.GameLog) != rules.NumAgents*rules.MaxTurns {
+				t.Fatalf("recorded %d turns, want %d", len(game.GameLog), rules.NumAgents*rules.MaxTurns)
+			}
+
+			for _, turn := range game.GameLog {
+				if turn.Error != nil {
+					t.Fatalf("Agent %d's turn %d failed: %v", turn.AgentID, turn.Turn, turn.Error)
+				}
+
+				for _, action := range turn.Actions {
+					// Agent 1 inspecting themselves is meant to fail
+					selfInspection := action.Action == "inspect_agent" && turn.AgentID == 1
+					if freeTools[action.Action] && !action.Success && !selfInspection {
+						t.Errorf("Agent %d's %s failed: %s", turn.AgentID, action.Action, action.Message)
+					}
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	openai "github.com/sashabaranov/go-openai"
+	"github.com/sashabaranov/go-openai/jsonschema"
+)
+
+// StructuredStrategy is an agent's plan and beliefs, given as JSON when the ruleset asks for
+// structured strategies
+type StructuredStrategy struct {
+	Goals          []string `json:"goals"`
+	PlannedActions []string `json:"planned_actions"`
+	Beliefs        []Belief `json:"beliefs"`
+	Confidence     float64  `json:"confidence"`
+}
+
+// Belief is what an agent thinks another agent is up to
+type Belief struct {
+	AgentID         int     `json:"agent_id"`
+	Intent          string  `json:"intent"`
+	Trustworthiness float64 `json:"trustworthiness"`
+	Reasoning       string  `json:"reasoning"`
+}
+
+// strategySchema is the JSON schema structured strategies must match. Every property is required
+// and no others are allowed, as strict structured outputs demand.
+func strategySchema() jsonschema.Definition {
+	return jsonschema.Definition{
+		Type: jsonschema.Object,
+		Properties: map[string]jsonschema.Definition{
+			"goals": {
+				Type:        jsonschema.Array,
+				Description: "What you are trying to achieve, most important first",
+				Items:       &jsonschema.Definition{Type: jsonschema.String},
+			},
+			"planned_actions": {
+				Type:        jsonschema.Array,
+				Description: "The actions you plan to take, in order",
+				Items:       &jsonschema.Definition{Type: jsonschema.String},
+			},
+			"beliefs": {
+				Type:        jsonschema.Array,
+				Description: "What you believe about each other agent",
+				Items: &jsonschema.Definition{
+					Type: jsonschema.Object,
+					Properties: map[string]jsonschema.Definition{
+						"agent_id": {
+							Type:        jsonschema.Integer,
+							Description: "The ID of the other agent",
+						},
+						"intent": {
+							Type:        jsonschema.String,
+							Description: "What you think the agent is trying to do, e.g. towards you",
+						},
+						"trustworthiness": {
+							Type:        jsonschema.Number,
+							Description: "How far you trust the agent to keep their word, from 0 (not at all) to 1 (completely)",
+						},
+						"reasoning": {
+							Type:        jsonschema.String,
+							Description: "Why you believe this",
+						},
+					},
+					Required:             []string{"agent_id", "intent", "trustworthiness", "reasoning"},
+					AdditionalProperties: false,
+				},
+			},
+			"confidence": {
+				Type:        jsonschema.Number,
+				Description: "How confident you are in your plan, from 0 to 1",
+			},
+		},
+		Required:             []string{"goals", "planned_actions", "beliefs", "confidence"},
+		AdditionalProperties: false,
+	}
+}
+
+// strategyFormat asks the model to answer with a structured strategy
+func strategyFormat() *openai.ChatCompletionResponseFormat {
+	return &openai.ChatCompletionResponseFormat{
+		Type: openai.ChatCompletionResponseFormatTypeJSONSchema,
+		JSONSchema: &openai.ChatCompletionResponseFormatJSONSchema{
+			Name:   "strategy",
+			Schema: strategySchema(),
+			Strict: true,
+		},
+	}
+}
+
+// parseStrategy checks a structured strategy against the schema and the game, as not every model
+// enforces the schema itself
+func (a *Agent) parseStrategy(g *Game, content string) (*StructuredStrategy, error) {
+	var value any
+	if err := json.Unmarshal([]byte(content), &value); err != nil {
+		return nil, fmt.Errorf("response is not valid JSON: %w", err)
+	}
+
+	if err := validateSchema(strategySchema(), value, ""); err != nil {
+		return nil, err
+	}
+
+	var strategy StructuredStrategy
+	if err := json.Unmarshal([]byte(content), &strategy); err != nil {
+		return nil, fmt.Errorf("failed to decode strategy: %w", err)
+	}
+
+	if strategy.Confidence < 0 || strategy.Confidence > 1 {
+		return nil, fmt.Errorf("confidence must be between 0 and 1")
+	}
+
+	for _, belief := range strategy.Beliefs {
+		if belief.AgentID == a.ID {
+			return nil, fmt.Errorf("beliefs must be about other agents, not yourself")
+		}
+
+		if belief.AgentID < 0 || belief.AgentID >= len(g.Agents) {
+			return nil, fmt.Errorf("there is no Agent %d", belief.AgentID)
+		}
+
+		if belief.Trustworthiness < 0 || belief.Trustworthiness > 1 {
+			return nil, fmt.Errorf("trustworthiness of Agent %d must be between 0 and 1", belief.AgentID)
+		}
+	}
+
+	return &strategy, nil
+}
+
+// structuredReasoning asks the agent to answer an instruction with a structured strategy. A response
+// that doesn't match the schema is sent back for correction up to InvalidActionRetries times, after
+// which it is kept as free text without a structured strategy.
+func (a *Agent) structuredReasoning(g *Game, instruction string) (string, *StructuredStrategy, error) {
+	a.AddTurnLog(instruction)
+
+	retriesLeft := g.rulesFor(a.ID).InvalidActionRetries
+	for {
+		request := g.chatRequest(a.Prompt)
+		request.ResponseFormat = strategyFormat()
+
+		content, err := getReasoningFromLM(g.ctx, a.llm, request)
+		if err != nil {
+			return "", nil, fmt.Errorf("failed to call LM: %w", err)
+		}
+
+		a.AddAgentMessage(content)
+
+		strategy, err := a.parseStrategy(g, content)
+		if err == nil {
+			return content, strategy, nil
+		}
+
+		if retriesLeft == 0 {
+			fmt.Printf("Agent %d: structured strategy was invalid, keeping it as text: %v\n", a.ID, err)
+			return content, nil, nil
+		}
+
+		retriesLeft--
+		a.AddTurnLog(fmt.Sprintf("Your response does not match the schema: %v. Please answer again with JSON that matches it.", err))
+	}
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseStrategy(t *testing.T) {
+	game := newTestGame(t, func(r *Ruleset) { r.NumAgents = 3 })
+	agent := &game.Agents[0]
+
+	belief := func(fields string) string {
+		return `{"goals": ["grow"], "planned_actions": ["buy_worker"], "confidence": 0.5, "beliefs": [` + fields + `]}`
+	}
+
+	tests := []struct {
+		name    string
+		content string
+		// err is part of the expected error, or empty if the strategy is valid
+		err string
+	}{
+		{"valid", belief(`{"agent_id": 1, "intent": "farming", "trustworthiness": 0.8, "reasoning": "kept their word"}`), ""},
+		{"no beliefs", belief(``), ""},
+		{"not JSON", "I will farm", "not valid JSON"},
+		{"missing field", `{"goals": [], "planned_actions": [], "beliefs": []}`, "missing required argument confidence"},
+		{"confidence out of range", `{"goals": [], "planned_actions": [], "beliefs": [], "confidence": 2}`, "confidence must be between 0 and 1"},
+		{"belief about themselves", belief(`{"agent_id": 0, "intent": "", "trustworthiness": 1, "reasoning": ""}`), "not yourself"},
+		{"unknown agent", belief(`{"agent_id": 3, "intent": "", "trustworthiness": 1, "reasoning": ""}`), "there is no Agent 3"},
+		{"trustworthiness out of range", belief(`{"agent_id": 2, "intent": "", "trustworthiness": -0.1, "reasoning": ""}`), "trustworthiness of Agent 2"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			strategy, err := agent.parseStrategy(game, tt.content)
+			if tt.err == "" {
+				if err != nil || strategy == nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Fatalf("got error %v, want %q", err, tt.err)
+			}
+		})
+	}
+}
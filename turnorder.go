@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// Turn orders
+const (
+	// FixedOrder gives agents their turns in ID order every round
+	FixedOrder = "fixed"
+	// RotatingOrder moves the first agent along by one each round
+	RotatingOrder = "rotating"
+	// ShuffledOrder draws a new order from the game's seed each round
+	ShuffledOrder = "shuffled"
+	// PoorestFirstOrder orders agents by their gold, least first
+	PoorestFirstOrder = "poorest_first"
+)
+
+// turnOrder returns the agent IDs in the order they act this round
+func (g *Game) turnOrder() []int {
+	n := len(g.Agents)
+	order := make([]int, n)
+	for i := range order {
+		order[i] = i
+	}
+
+	switch g.Rules.turnOrder() {
+	case RotatingOrder:
+		start := g.CurrentTurn % n
+		order = append(order[start:], order[:start]...)
+	case ShuffledOrder:
+		order = g.rng.Perm(n)
+	case PoorestFirstOrder:
+		sort.SliceStable(order, func(i, j int) bool {
+			return g.Agents[order[i]].Gold < g.Agents[order[j]].Gold
+		})
+	}
+
+	return order
+}
+
+// turnOrder resolves the default order for the turn mode. A fixed order would give the same agent
+// first claim on every contested action when turns are simultaneous, so they rotate by default.
+func (r Ruleset) turnOrder() string {
+	if r.TurnOrder != "" {
+		return r.TurnOrder
+	}
+
+	if r.TurnMode == SimultaneousTurns {
+		return RotatingOrder
+	}
+
+	return FixedOrder
+}
+
+// announceTurnOrder tells every agent the order for the coming round
+func (g *Game) announceTurnOrder(order []int) {
+	agents := []string{}
+	for _, id := range order {
+		if !g.Agents[id].Lost {
+			agents = append(agents, fmt.Sprintf("Agent %d", id))
+		}
+	}
+
+	verb := "act"
+	if g.Rules.TurnMode == SimultaneousTurns {
+		verb = "have their actions resolved"
+	}
+
+	g.broadcastMessage(fmt.Sprintf("Round %d is starting. Agents will %s in this order: %s", g.CurrentTurn+1, verb, strings.Join(agents, ", ")), -1)
+}
+
+func validTurnOrder(order string) bool {
+	return order == "" || slices.Contains([]string{FixedOrder, RotatingOrder, ShuffledOrder, PoorestFirstOrder}, order)
+}
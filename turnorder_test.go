@@ -0,0 +1,90 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+// withTurnOrder configures a game of four agents with a fixed seed to take turns in the given order
+func withTurnOrder(order string) func(*Ruleset) {
+	return func(r *Ruleset) {
+		r.NumAgents = 4
+		r.TurnOrder = order
+		r.Seed = 7
+	}
+}
+
+func TestFixedOrder(t *testing.T) {
+	game := newTestGame(t, withTurnOrder(FixedOrder))
+
+	for turn := 0; turn < 3; turn++ {
+		game.CurrentTurn = turn
+		if got := game.turnOrder(); !slices.Equal(got, []int{0, 1, 2, 3}) {
+			t.Errorf("round %d: order %v, want [0 1 2 3]", turn, got)
+		}
+	}
+}
+
+func TestRotatingOrder(t *testing.T) {
+	game := newTestGame(t, withTurnOrder(RotatingOrder))
+
+	want := [][]int{{0, 1, 2, 3}, {1, 2, 3, 0}, {2, 3, 0, 1}, {3, 0, 1, 2}, {0, 1, 2, 3}}
+	for turn, order := range want {
+		game.CurrentTurn = turn
+		if got := game.turnOrder(); !slices.Equal(got, order) {
+			t.Errorf("round %d: order %v, want %v", turn, got, order)
+		}
+	}
+}
+
+// TestShuffledOrder checks shuffled orders are permutations that replay the same from the same seed
+func TestShuffledOrder(t *testing.T) {
+	first := newTestGame(t, withTurnOrder(ShuffledOrder))
+	second := newTestGame(t, withTurnOrder(ShuffledOrder))
+
+	for turn := 0; turn < 5; turn++ {
+		a, b := first.turnOrder(), second.turnOrder()
+		if !slices.Equal(a, b) {
+			t.Fatalf("round %d: games with the same seed drew orders %v and %v", turn, a, b)
+		}
+
+		sorted := slices.Clone(a)
+		slices.Sort(sorted)
+		if !slices.Equal(sorted, []int{0, 1, 2, 3}) {
+			t.Fatalf("round %d: order %v is not a permutation of the agents", turn, a)
+		}
+	}
+}
+
+func TestPoorestFirstOrder(t *testing.T) {
+	game := newTestGame(t, withTurnOrder(PoorestFirstOrder))
+
+	// Agents with the same gold keep their ID order
+	for i, gold := range []int{30, 10, 30, 5} {
+		game.Agents[i].Gold = gold
+	}
+
+	if got := game.turnOrder(); !slices.Equal(got, []int{3, 1, 0, 2}) {
+		t.Errorf("order %v, want [3 1 0 2]", got)
+	}
+}
+
+func TestDefaultTurnOrder(t *testing.T) {
+	tests := []struct {
+		order string
+		mode  string
+		want  string
+	}{
+		{"", SequentialTurns, FixedOrder},
+		{"", SimultaneousTurns, RotatingOrder},
+		{ShuffledOrder, SimultaneousTurns, ShuffledOrder},
+		{FixedOrder, SimultaneousTurns, FixedOrder},
+	}
+
+	for _, tt := range tests {
+		rules := Ruleset{TurnOrder: tt.order, TurnMode: tt.mode}
+		if got := rules.turnOrder(); got != tt.want {
+			t.Errorf("order %q in %s mode resolved to %q, want %q", tt.order, tt.mode, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"strings"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+// modelPrices is the price of known models in dollars per million prompt and completion tokens,
+// matched by prefix, most specific first. Calls to other models are counted but not priced.
+var modelPrices = []struct {
+	prefix     string
+	prompt     float64
+	completion float64
+}{
+	{"gpt-4o-mini", 0.15, 0.6},
+	{"gpt-4o", 2.5, 10},
+	{"gpt-4-turbo", 10, 30},
+	{"gpt-4-1106", 10, 30},
+	{"gpt-4-0125", 10, 30},
+	{"gpt-4-32k", 60, 120},
+	{"gpt-4", 30, 60},
+	{"gpt-3.5-turbo-instruct", 1.5, 2},
+	{"gpt-3.5-turbo", 0.5, 1.5},
+}
+
+// Usage counts the calls made to the model, the tokens they used and their estimated cost in dollars
+type Usage struct {
+	Calls            int
+	PromptTokens     int
+	CompletionTokens int
+	Cost             float64
+}
+
+// add counts a call answered by the given model
+func (u *Usage) add(model string, usage openai.Usage) {
+	u.Calls++
+	u.PromptTokens += usage.PromptTokens
+	u.CompletionTokens += usage.CompletionTokens
+	u.Cost += callCost(model, usage)
+}
+
+// plus returns the combined usage
+func (u Usage) plus(other Usage) Usage {
+	return Usage{
+		Calls:            u.Calls + other.Calls,
+		PromptTokens:     u.PromptTokens + other.PromptTokens,
+		CompletionTokens: u.CompletionTokens + other.CompletionTokens,
+		Cost:             u.Cost + other.Cost,
+	}
+}
+
+// since returns the usage added after start
+func (u Usage) since(start Usage) Usage {
+	return Usage{
+		Calls:            u.Calls - start.Calls,
+		PromptTokens:     u.PromptTokens - start.PromptTokens,
+		CompletionTokens: u.CompletionTokens - start.CompletionTokens,
+		Cost:             u.Cost - start.Cost,
+	}
+}
+
+// callCost estimates the cost of a call in dollars
+func callCost(model string, usage openai.Usage) float64 {
+	for _, price := range modelPrices {
+		if strings.HasPrefix(model, price.prefix) {
+			return (float64(usage.PromptTokens)*price.prompt + float64(usage.CompletionTokens)*price.completion) / 1e6
+		}
+	}
+
+	return 0
+}
+
+// usage totals every agent's usage of the model
+func (g *Game) usage() Usage {
+	total := Usage{}
+	for i := range g.Agents {
+		total = total.plus(g.Agents[i].Usage)
+	}
+
+	return total
+}
+
+// overBudget reports whether the game has spent more than MaxCost
+func (g *Game) overBudget() bool {
+	return g.Rules.MaxCost > 0 && g.usage().Cost > g.Rules.MaxCost
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"math"
+	"testing"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+func TestCallCost(t *testing.T) {
+	usage := openai.Usage{PromptTokens: 1000000, CompletionTokens: 1000000}
+
+	tests := []struct {
+		model string
+		want  float64
+	}{
+		{"gpt-4o-mini-2024-07-18", 0.75},
+		{"gpt-4o", 12.5},
+		{"gpt-3.5-turbo-0125", 2},
+		{"unknown-model", 0},
+	}
+
+	for _, tt := range tests {
+		if got := callCost(tt.model, usage); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("%s: cost %v, want %v", tt.model, got, tt.want)
+		}
+	}
+}
+
+func TestUsageIsRecordedPerTurn(t *testing.T) {
+	game := newTestGame(t, func(r *Ruleset) {
+		r.NumAgents = 2
+		r.MaxTurns = 2
+	})
+	RunGame(game)
+
+	if len(game.GameLog) != 4 {
+		t.Fatalf("recorded %d turns, want 4", len(game.GameLog))
+	}
+
+	total := Usage{}
+	for _, turn := range game.GameLog {
+		if turn.Usage.Calls == 0 || turn.Usage.PromptTokens != 100*turn.Usage.Calls {
+			t.Errorf("Agent %d's turn %d used %+v", turn.AgentID, turn.Turn, turn.Usage)
+		}
+
+		total = total.plus(turn.Usage)
+		if turn.GameUsage.Calls != total.Calls {
+			t.Errorf("after Agent %d's turn %d the game has made %d calls, want %d", turn.AgentID, turn.Turn, turn.GameUsage.Calls, total.Calls)
+		}
+	}
+
+	if used := game.usage(); used.Calls != total.Calls || used.PromptTokens != total.PromptTokens || math.Abs(used.Cost-total.Cost) > 1e-12 {
+		t.Errorf("game used %+v, turns add up to %+v", used, total)
+	}
+}
+
+func TestBudgetEndsGame(t *testing.T) {
+	game := newTestGame(t, func(r *Ruleset) {
+		r.NumAgents = 2
+		r.MaxTurns = 5
+		// A single call costs more than this
+		r.MaxCost = 0.00001
+	})
+	RunGame(game)
+
+	if !game.OverBudget {
+		t.Fatal("game wasn't ended for going over budget")
+	}
+
+	if len(game.GameLog) != 1 {
+		t.Errorf("%d turns were played, want the game to end after the first", len(game.GameLog))
+	}
+}
@@ -0,0 +1,58 @@
+import React, { useState } from 'react';
+import { ChatMessage } from './Game';
+import {
+  Card,
+  CardContent,
+  CardHeader,
+  CardTitle,
+} from "./components/ui/card"
+
+interface ChatLogProps {
+  messages: ChatMessage[];
+}
+
+const ChatLog: React.FC<ChatLogProps> = ({ messages }) => {
+  const [channelID, setChannelID] = useState<number | null>(null);
+
+  // Every channel that has had a message, in the order they were created
+  const channels = Array.from(new Map(messages.map(message => [message.ChannelID, message.Channel] as [number, string])))
+    .sort(([a], [b]) => a - b);
+
+  const shown = channelID === null ? messages : messages.filter(message => message.ChannelID === channelID);
+
+  function channelButton(id: number | null, name: string) {
+    return (
+      <button
+        key={id ?? 'all'}
+        className={`px-2 py-1 rounded ${channelID === id ? 'bg-gray-900 text-white' : 'bg-gray-100'}`}
+        onClick={() => setChannelID(id)}
+      >
+        {name}
+      </button>
+    );
+  }
+
+  return (
+    <Card className="font-mono text-left mb-4">
+      <CardHeader>
+        <CardTitle>💬 Chat</CardTitle>
+        <div className="flex flex-row flex-wrap gap-2 text-sm">
+          {channelButton(null, 'all')}
+          {channels.map(([id, name]) => channelButton(id, `#${name}`))}
+        </div>
+      </CardHeader>
+      <CardContent>
+        <div className="flex flex-col space-y-1 max-h-96 overflow-y-auto text-sm">
+          {shown.map((message, idx) => (
+            <p key={idx}>
+              <span className="text-gray-400">R{message.Turn + 1} #{message.Channel}</span>{' '}
+              <strong>Agent {message.AgentID}:</strong> {message.Message}
+            </p>
+          ))}
+        </div>
+      </CardContent>
+    </Card>
+  );
+};
+
+export default ChatLog;
@@ -1,5 +1,7 @@
 import React, { useEffect, useState } from 'react';
 import TurnDisplay from './TurnDisplay';
+import ChatLog from './ChatLog';
+import PromiseTracker from './PromiseTracker';
 import OpenAI from 'openai';
 import { Input } from './components/ui/input';
 import { ActionBar } from './components/ActionBar';
@@ -8,18 +10,94 @@ export interface AgentState {
   Gold: number;
   Wheat: number;
   Workers: number;
-  Buildings: { Type: string, Manned: boolean }[];
+  Walls: number;
+  Guards: number;
+  Buildings: { Type: string, Manned: boolean, DisabledTurns: number }[];
+}
+
+export interface GameEvent {
+  Turn: number;
+  Kind: string;
+  AgentID: number;
+  TargetID: number;
+  Success: boolean;
+  Message: string;
+}
+
+export interface ActionResult {
+  ToolCallID: string;
+  Action: string;
+  Arguments: string;
+  Success: boolean;
+  ErrorCode: string;
+  Message: string;
+  Delta: { Gold: number, Wheat: number, Workers: number, Walls: number, Guards: number, Buildings: number };
+}
+
+export interface StructuredStrategy {
+  goals: string[];
+  planned_actions: string[];
+  beliefs: { agent_id: number, intent: string, trustworthiness: number, reasoning: string }[];
+  confidence: number;
+}
+
+export interface Usage {
+  Calls: number;
+  PromptTokens: number;
+  CompletionTokens: number;
+  Cost: number;
+}
+
+export interface ChatMessage {
+  Turn: number;
+  ChannelID: number;
+  Channel: string;
+  AgentID: number;
+  Message: string;
+}
+
+export interface AgentPromise {
+  ID: number;
+  Turn: number;
+  FromAgent: number;
+  ToAgent: number;
+  Resource: { Type: string, Amount: number };
+  Deadline: number;
+  Delivered: number;
+  Status: string;
+}
+
+export interface Reliability {
+  AgentID: number;
+  Kept: number;
+  Broken: number;
+  Pending: number;
+  Score: number;
 }
 
 export interface AgentTurn {
   AgentID: number;
   StartState: AgentState;
   Strategy: string;
-  Action: string;
+  Actions: ActionResult[] | null;
   EndState: AgentState;
   FullPrompt: OpenAI.ChatCompletionMessage[];
   PostRationalisation: string;
+  Events: GameEvent[] | null;
   Error: string | null;
+  Forfeited: boolean;
+  ContextTokens: number;
+  Notes: { Turn: number, Text: string }[] | null;
+  PromptVersion: string;
+  StructuredStrategy: StructuredStrategy | null;
+  StructuredRationalisation: StructuredStrategy | null;
+  Usage: Usage;
+  AgentUsage: Usage;
+  GameUsage: Usage;
+  ChatMessages: ChatMessage[] | null;
+  Negotiation: ActionResult[] | null;
+  Promises: AgentPromise[] | null;
+  Reliability: Reliability[] | null;
   Turn: number;
 }
 
@@ -126,6 +204,15 @@ const GameSimulation: React.FC = () => {
       )}
 
       <div className="container px-0 py-4">
+        {gameState.some(turn => turn.ChatMessages && turn.ChatMessages.length > 0) && (
+          <ChatLog messages={gameState.flatMap(turn => turn.ChatMessages ?? [])} />
+        )}
+        {gameState.some(turn => turn.Promises && turn.Promises.length > 0) && (
+          <PromiseTracker
+            promises={gameState.flatMap(turn => turn.Promises ?? [])}
+            reliability={gameState[gameState.length - 1].Reliability ?? []}
+          />
+        )}
         <div className="grid grid-cols-3 gap-4">
           {Object.entries(gameState).map(([turnID, turns]) => (
             <TurnDisplay key={turnID} agentTurn={turns} />
@@ -0,0 +1,52 @@
+import React from 'react';
+import { AgentPromise, Reliability } from './Game';
+import {
+  Card,
+  CardContent,
+  CardHeader,
+  CardTitle,
+} from "./components/ui/card"
+import { Label } from "./components/ui/label"
+
+interface PromiseTrackerProps {
+  promises: AgentPromise[];
+  reliability: Reliability[];
+}
+
+const PromiseTracker: React.FC<PromiseTrackerProps> = ({ promises, reliability }) => {
+  // Promises are sent again each time their status changes, so keep the latest of each
+  const latest = Array.from(new Map(promises.map(promise => [promise.ID, promise] as [number, AgentPromise])).values());
+  const broken = latest.filter(promise => promise.Status === 'broken');
+
+  return (
+    <Card className="font-mono text-left mb-4">
+      <CardHeader>
+        <CardTitle>🤞 Promises</CardTitle>
+      </CardHeader>
+      <CardContent>
+        <div className="grid w-full items-start gap-4">
+          <div className="flex flex-col space-y-1.5">
+            <Label>Reliability</Label>
+            {reliability.map(score => (
+              <p key={score.AgentID}>
+                Agent {score.AgentID}: {score.Kept + score.Broken > 0 ? `${Math.round(score.Score * 100)}%` : '–'} ({score.Kept} kept, {score.Broken} broken, {score.Pending} pending)
+              </p>
+            ))}
+          </div>
+          {broken.length > 0 && (
+            <div className="flex flex-col space-y-1.5">
+              <Label>💔 Broken promises</Label>
+              {broken.map(promise => (
+                <p key={promise.ID} className="text-red-500">
+                  Agent {promise.FromAgent} promised Agent {promise.ToAgent} {promise.Resource.Amount} {promise.Resource.Type} by round {promise.Deadline + 1}, gave {promise.Delivered}
+                </p>
+              ))}
+            </div>
+          )}
+        </div>
+      </CardContent>
+    </Card>
+  );
+};
+
+export default PromiseTracker;
@@ -1,5 +1,5 @@
 import React from 'react';
-import { AgentTurn } from './Game';
+import { AgentTurn, StructuredStrategy } from './Game';
 import {
   Card,
   CardContent,
@@ -20,19 +20,36 @@ const TurnDisplay: React.FC<TurnDisplayProps> = ({ agentTurn }) => {
     return Array(count).fill(emoji).join('');
   }
 
-  function buildingsString(buildings: { Type: string, Manned: boolean }[]) {
+  function buildingsString(buildings: { Type: string, Manned: boolean, DisabledTurns: number }[]) {
     return buildings.map(building => {
-      return `${building.Type === 'Farm' ? '🚜' : '⛏️'} ${building.Manned ? '(manned)' : '(unmanned)'}`;
+      return `${building.Type === 'Farm' ? '🚜' : '⛏️'} ${building.Manned ? '(manned)' : '(unmanned)'}${building.DisabledTurns > 0 ? ' (sabotaged)' : ''}`;
     }).join(', ');
   }
 
+  function strategyView(strategy: StructuredStrategy | null, text: string) {
+    if (!strategy) {
+      return <p>{text}</p>;
+    }
+
+    return (
+      <div className="flex flex-col space-y-1">
+        <p>Goals: {strategy.goals.join('; ')}</p>
+        <p>Plan: {strategy.planned_actions.join('; ')}</p>
+        {strategy.beliefs.map((belief, idx) => (
+          <p key={idx} className="text-gray-600">Agent {belief.agent_id} (trust {belief.trustworthiness.toFixed(2)}): {belief.intent}</p>
+        ))}
+        <p>Confidence: {strategy.confidence.toFixed(2)}</p>
+      </div>
+    );
+  }
+
   return (
     <Card className="col-span-1 font-mono text-left">
       <CardHeader>
-        <CardTitle>Agent {agentTurn.AgentID} / / Turn {agentTurn.Turn}</CardTitle>
+        <CardTitle>Agent {agentTurn.AgentID} / / Turn {agentTurn.Turn}{agentTurn.Forfeited && ' / / Forfeited'}</CardTitle>
         <CardDescription className="space-y-4">
           <span className="text-lg">
-            Action: {agentTurn.Action}
+            Actions: {agentTurn.Actions?.map(action => action.Action).join(', ')}
           </span>
         </CardDescription>
       </CardHeader>
@@ -42,18 +59,55 @@ const TurnDisplay: React.FC<TurnDisplayProps> = ({ agentTurn }) => {
             <p>Gold: 🪙 {agentTurn.StartState.Gold} --&gt; {agentTurn.EndState.Gold}</p>
             <p>Wheat: {emojiString(agentTurn.EndState.Wheat, '🌾')} {agentTurn.StartState.Wheat} --&gt; {agentTurn.EndState.Wheat}</p>
             <p>Workers: {emojiString(agentTurn.StartState.Workers, '👷')} {agentTurn.StartState.Workers} --&gt; {agentTurn.EndState.Workers}</p>
+            <p>Defences: 🧱 {agentTurn.EndState.Walls} walls, 💂 {agentTurn.EndState.Guards} guards</p>
             {(agentTurn.StartState.Buildings && agentTurn.EndState.Buildings) && (
               <p>Buildings: {buildingsString(agentTurn.EndState.Buildings)} {agentTurn.StartState.Buildings.length} --&gt; {agentTurn.EndState.Buildings.length}</p>
             )}
           </div>
+          {agentTurn.Negotiation && agentTurn.Negotiation.length > 0 && (
+            <div className="flex flex-col space-y-1.5">
+              <Label>🤝 Negotiation</Label>
+              {agentTurn.Negotiation.map((action, idx) => (
+                <p key={idx} className={action.Success ? 'text-green-600' : 'text-red-500'}>
+                  {action.Action}{action.Success ? '' : ` (${action.ErrorCode})`}: {action.Message}
+                </p>
+              ))}
+            </div>
+          )}
+          {agentTurn.Actions && agentTurn.Actions.length > 0 && (
+            <div className="flex flex-col space-y-1.5">
+              <Label>🎬 Actions</Label>
+              {agentTurn.Actions.map((action, idx) => (
+                <p key={idx} className={action.Success ? 'text-green-600' : 'text-red-500'}>
+                  {action.Action}{action.Success ? '' : ` (${action.ErrorCode})`}: {action.Message}
+                </p>
+              ))}
+            </div>
+          )}
           <div className="flex flex-col space-y-1.5">
             <Label>🧠 Strategy</Label>
-            <p>{agentTurn.Strategy}</p>
+            {strategyView(agentTurn.StructuredStrategy, agentTurn.Strategy)}
           </div>
           <div className="flex flex-col space-y-1.5">
             <Label>💭 Post Rationalisation</Label>
-            <p>{agentTurn.PostRationalisation}</p>
+            {strategyView(agentTurn.StructuredRationalisation, agentTurn.PostRationalisation)}
           </div>
+          {agentTurn.Notes && agentTurn.Notes.length > 0 && (
+            <div className="flex flex-col space-y-1.5">
+              <Label>📓 Notebook</Label>
+              {agentTurn.Notes.map((note, idx) => (
+                <p key={idx} className="text-gray-600">Turn {note.Turn}: {note.Text}</p>
+              ))}
+            </div>
+          )}
+          {agentTurn.Events && agentTurn.Events.length > 0 && (
+            <div className="flex flex-col space-y-1.5">
+              <Label>⚔️ Events</Label>
+              {agentTurn.Events.map((event, idx) => (
+                <p key={idx} className={event.Success ? 'text-green-600' : 'text-gray-500'}>{event.Message}</p>
+              ))}
+            </div>
+          )}
           {agentTurn.Error && (
             <div className="flex flex-col space-y-1.5">
               <Label>⚠️ Error:</Label>
@@ -63,6 +117,12 @@ const TurnDisplay: React.FC<TurnDisplayProps> = ({ agentTurn }) => {
         </div>
       </CardContent>
       <CardFooter className="flex justify-between">
+        <div className="flex flex-col">
+          <span className="text-sm text-gray-500">~{agentTurn.ContextTokens} tokens in context / / prompt {agentTurn.PromptVersion}</span>
+          <span className="text-sm text-gray-500">
+            {agentTurn.Usage.PromptTokens + agentTurn.Usage.CompletionTokens} tokens (${agentTurn.Usage.Cost.toFixed(4)}) this turn / / Agent ${agentTurn.AgentUsage.Cost.toFixed(4)} / / Game ${agentTurn.GameUsage.Cost.toFixed(4)}
+          </span>
+        </div>
         {agentTurn.FullPrompt && (
           <PromptModal agentTurn={agentTurn} />
         )}